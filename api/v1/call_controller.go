@@ -0,0 +1,26 @@
+package v1
+
+import (
+	"gochat/internal/dto/request"
+	"gochat/internal/service/gorm"
+	"gochat/pkg/constants"
+	"gochat/pkg/zlog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetIceServers 获取发起通话所需的ICE服务器和TURN临时凭证
+func GetIceServers(c *gin.Context) {
+	var req request.GetIceServersRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.CallService.GetIceServers(req)
+	JsonBack(c, message, ret, rsp)
+}
@@ -0,0 +1,109 @@
+package v1
+
+import (
+	"gochat/internal/dto/request"
+	"gochat/internal/service/gorm"
+	"gochat/pkg/constants"
+	"gochat/pkg/zlog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFileDownloadUrl 获取聊天文件的签名下载地址
+func GetFileDownloadUrl(c *gin.Context) {
+	var req request.GetFileDownloadUrlRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.MessageService.GetFileDownloadUrl(req)
+	JsonBack(c, message, ret, rsp)
+}
+
+// DownloadFile 通过签名下载地址下载聊天文件
+func DownloadFile(c *gin.Context) {
+	localPath, downloadUrl, ret := gorm.MessageService.ResolveDownload(c.Request.URL.Query())
+	switch {
+	case ret == -2:
+		c.Status(http.StatusForbidden)
+	case ret == -3:
+		c.Status(http.StatusNotFound)
+	case ret != 0:
+		c.Status(http.StatusInternalServerError)
+	case localPath != "":
+		c.File(localPath)
+	default:
+		c.Redirect(http.StatusFound, downloadUrl)
+	}
+}
+
+// InitUpload 创建分片上传会话
+func InitUpload(c *gin.Context) {
+	var req request.InitUploadRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.ChunkUploadService.InitUpload(req)
+	JsonBack(c, message, ret, rsp)
+}
+
+// UploadPart 上传一个分片
+func UploadPart(c *gin.Context) {
+	message, rsp, ret := gorm.ChunkUploadService.UploadPart(c)
+	JsonBack(c, message, ret, rsp)
+}
+
+// GetUploadStatus 查询分片上传状态
+func GetUploadStatus(c *gin.Context) {
+	var req request.UploadSessionRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.ChunkUploadService.GetUploadStatus(req)
+	JsonBack(c, message, ret, rsp)
+}
+
+// CompleteUpload 完成分片上传
+func CompleteUpload(c *gin.Context) {
+	var req request.UploadSessionRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.ChunkUploadService.CompleteUpload(req)
+	JsonBack(c, message, ret, rsp)
+}
+
+// AbortUpload 取消分片上传
+func AbortUpload(c *gin.Context) {
+	var req request.UploadSessionRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.ChunkUploadService.AbortUpload(req)
+	JsonBack(c, message, ret, nil)
+}
@@ -130,6 +130,12 @@ func UpdateGroupInfo(c *gin.Context) {
 	JsonBack(c, message, ret, nil)
 }
 
+// UpdateGroupAvatar 更新群聊头像
+func UpdateGroupAvatar(c *gin.Context) {
+	message, rsp, ret := gorm.AvatarService.UpdateGroupAvatar(c)
+	JsonBack(c, message, ret, rsp)
+}
+
 // GetGroupMemberList 获取群聊成员列表
 func GetGroupMemberList(c *gin.Context) {
 	var req request.GetGroupMemberListRequest
@@ -195,3 +201,123 @@ func SetGroupsStatus(c *gin.Context) {
 	message, ret := gorm.GroupInfoService.SetGroupsStatus(req.UuidList, req.Status)
 	JsonBack(c, message, ret, nil)
 }
+
+// SetGroupAdmins 设置或取消群管理员
+func SetGroupAdmins(c *gin.Context) {
+	var req request.SetGroupAdminsRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.GroupInfoService.SetGroupAdmins(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// MuteGroupMembers 禁言群成员
+func MuteGroupMembers(c *gin.Context) {
+	var req request.MuteGroupMembersRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.GroupInfoService.MuteGroupMembers(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// UnmuteGroupMembers 解除群成员禁言
+func UnmuteGroupMembers(c *gin.Context) {
+	var req request.UnmuteGroupMembersRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.GroupInfoService.UnmuteGroupMembers(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// SetGroupMuteAll 开启或关闭全员禁言
+func SetGroupMuteAll(c *gin.Context) {
+	var req request.SetGroupMuteAllRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.GroupInfoService.SetGroupMuteAll(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// GetJoinApplyList 获取加群申请列表
+func GetJoinApplyList(c *gin.Context) {
+	var req request.GetJoinApplyListRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, applyList, ret := gorm.GroupInfoService.GetJoinApplyList(req)
+	JsonBack(c, message, ret, applyList)
+}
+
+// PassJoinApply 通过加群申请
+func PassJoinApply(c *gin.Context) {
+	var req request.HandleJoinApplyRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.GroupInfoService.PassJoinApply(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// RefuseJoinApply 拒绝加群申请
+func RefuseJoinApply(c *gin.Context) {
+	var req request.HandleJoinApplyRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.GroupInfoService.RefuseJoinApply(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// BlackJoinApply 拉黑加群申请
+func BlackJoinApply(c *gin.Context) {
+	var req request.HandleJoinApplyRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.GroupInfoService.BlackJoinApply(req)
+	JsonBack(c, message, ret, nil)
+}
@@ -0,0 +1,86 @@
+package v1
+
+import (
+	"gochat/internal/dto/request"
+	"gochat/internal/service/gorm"
+	"gochat/pkg/constants"
+	"gochat/pkg/zlog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InviteGroupMembers 邀请好友加入群聊
+func InviteGroupMembers(c *gin.Context) {
+	var req request.InviteGroupMembersRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.GroupInviteService.InviteGroupMembers(req)
+	JsonBack(c, message, ret, rsp)
+}
+
+// CreateGroupInvite 创建群聊邀请链接
+func CreateGroupInvite(c *gin.Context) {
+	var req request.CreateGroupInviteRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.GroupInviteService.CreateGroupInvite(req)
+	JsonBack(c, message, ret, rsp)
+}
+
+// GetGroupInviteList 获取群聊邀请链接列表
+func GetGroupInviteList(c *gin.Context) {
+	var req request.GetGroupInviteListRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.GroupInviteService.GetGroupInviteList(req)
+	JsonBack(c, message, ret, rsp)
+}
+
+// RevokeGroupInvite 撤销群聊邀请链接
+func RevokeGroupInvite(c *gin.Context) {
+	var req request.RevokeGroupInviteRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.GroupInviteService.RevokeGroupInvite(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// JoinGroupByInvite 通过邀请码加入群聊
+func JoinGroupByInvite(c *gin.Context) {
+	var req request.JoinGroupByInviteRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, groupId, ret := gorm.GroupInviteService.JoinGroupByInvite(req)
+	JsonBack(c, message, ret, groupId)
+}
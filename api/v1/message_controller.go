@@ -33,18 +33,33 @@ func GetGroupMessageList(c *gin.Context) {
 		})
 		return
 	}
-	message, rsp, ret := gorm.MessageService.GetGroupMessageList(req.GroupId)
+	message, rsp, ret := gorm.MessageService.GetGroupMessageList(req.OwnerId, req.GroupId)
 	JsonBack(c, message, ret, rsp)
 }
 
 // UploadAvatar 上传头像
 func UploadAvatar(c *gin.Context) {
-	message, ret := gorm.MessageService.UploadAvatar(c)
-	JsonBack(c, message, ret, nil)
+	message, rsp, ret := gorm.MessageService.UploadAvatar(c)
+	JsonBack(c, message, ret, rsp)
 }
 
 // UploadFile 上传文件
 func UploadFile(c *gin.Context) {
-	message, ret := gorm.MessageService.UploadFile(c)
-	JsonBack(c, message, ret, nil)
+	message, rsp, ret := gorm.MessageService.UploadFile(c)
+	JsonBack(c, message, ret, rsp)
+}
+
+// GetStaticObject 访问上传的文件，使用S3驱动时重定向到对象存储的预签名下载地址
+func GetStaticObject(c *gin.Context) {
+	localPath, downloadUrl, ret := gorm.MessageService.GetStaticObject(c.Param("filepath"))
+	switch {
+	case ret == -2:
+		c.Status(http.StatusNotFound)
+	case ret != 0:
+		c.Status(http.StatusInternalServerError)
+	case localPath != "":
+		c.File(localPath)
+	default:
+		c.Redirect(http.StatusFound, downloadUrl)
+	}
 }
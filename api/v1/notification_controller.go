@@ -0,0 +1,41 @@
+package v1
+
+import (
+	"gochat/internal/dto/request"
+	"gochat/internal/service/gorm"
+	"gochat/pkg/constants"
+	"gochat/pkg/zlog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotificationList 获取系统通知列表
+func GetNotificationList(c *gin.Context) {
+	var req request.OwnlistRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.NotificationService.GetNotificationList(req.OwnerId)
+	JsonBack(c, message, ret, rsp)
+}
+
+// ReadNotification 将系统通知标记为已读
+func ReadNotification(c *gin.Context) {
+	var req request.ReadNotificationRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.NotificationService.ReadNotification(req)
+	JsonBack(c, message, ret, nil)
+}
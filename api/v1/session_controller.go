@@ -84,3 +84,18 @@ func CheckOpenSessionAllowed(c *gin.Context) {
 	message, res, ret := gorm.SessionService.CheckOpenSessionAllowed(req.SendId, req.ReceiveId)
 	JsonBack(c, message, ret, res)
 }
+
+// UpdateSessionSetting 更新会话免打扰、置顶和归档设置
+func UpdateSessionSetting(c *gin.Context) {
+	var req request.UpdateSessionSettingRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.SessionService.UpdateSessionSetting(req)
+	JsonBack(c, message, ret, nil)
+}
@@ -77,6 +77,12 @@ func UpdateUserInfo(c *gin.Context) {
 	JsonBack(c, message, ret, nil)
 }
 
+// UpdateUserAvatar 更新用户头像
+func UpdateUserAvatar(c *gin.Context) {
+	message, rsp, ret := gorm.AvatarService.UpdateUserAvatar(c)
+	JsonBack(c, message, ret, rsp)
+}
+
 // GetUserInfoList 获取用户列表
 func GetUserInfoList(c *gin.Context) {
 	var req request.GetUserInfoListRequest
@@ -38,3 +38,24 @@ func WsLogout(c *gin.Context) {
 	message, ret := chat.ClientLogout(req.OwnerId)
 	JsonBack(c, message, ret, nil)
 }
+
+// GetOnlineStatus 获取用户在线状态
+func GetOnlineStatus(c *gin.Context) {
+	var req request.GetOnlineStatusRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rspList, ret := chat.GetOnlineStatus(req.UuidList)
+	JsonBack(c, message, ret, rspList)
+}
+
+// GetPipelineStats 获取消息处理流水线指标
+func GetPipelineStats(c *gin.Context) {
+	message, stats, ret := chat.GetPipelineStats()
+	JsonBack(c, message, ret, stats)
+}
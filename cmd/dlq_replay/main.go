@@ -0,0 +1,39 @@
+// Package main 提供死信重放工具
+// 将死信主题中因解析、保存失败而被转存的聊天消息重新投递回聊天主题
+// 使用示例：
+//
+//	go run ./cmd/dlq_replay -limit 100 -idle 5s
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"gochat/internal/service/kafka" // Kafka服务
+	"gochat/pkg/zlog"               // 日志工具
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	limit := flag.Int("limit", 0, "最多重放的消息数量，0表示全部重放")
+	idle := flag.Duration("idle", 5*time.Second, "等待新死信的最长时间，超时后退出")
+	flag.Parse()
+
+	// 收到中断信号时停止重放，已重放的消息偏移量已提交
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	kafka.KafkaService.KafkaInit()
+	replayed, err := kafka.KafkaService.ReplayDeadLetter(ctx, *limit, *idle)
+	// 关闭写入器时会刷新尚未发送的消息
+	kafka.KafkaService.KafkaClose()
+
+	zlog.Info(fmt.Sprintf("已重放死信消息%d条", replayed))
+	if err != nil {
+		zlog.Error(err.Error())
+		os.Exit(1)
+	}
+}
@@ -2,25 +2,32 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"gochat/internal/config"                // 配置管理
-	"gochat/internal/https_server"          // HTTPS服务器
-	"gochat/internal/service/chat"          // 聊天服务
-	"gochat/internal/service/kafka"         // Kafka服务
-	myredis "gochat/internal/service/redis" // Redis服务
-	"gochat/pkg/zlog"                       // 日志工具
+	"gochat/internal/config"        // 配置管理
+	"gochat/internal/https_server"  // HTTPS服务器
+	"gochat/internal/service/chat"  // 聊天服务
+	"gochat/internal/service/gorm"  // 数据库服务
+	"gochat/internal/service/kafka" // Kafka服务
+	"gochat/pkg/zlog"               // 日志工具
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
+// defaultShutdownTimeout 未配置优雅关闭等待时间时使用的默认值，单位秒
+const defaultShutdownTimeout = 15
+
 // main 函数是GoChat服务器的入口点
 // 功能：
 //  1. 加载配置
 //  2. 初始化消息服务（Kafka或Channel模式）
 //  3. 启动HTTPS服务器
 //  4. 设置信号监听，处理优雅关闭
-//  5. 清理资源（关闭Kafka、清理Redis）
+//  5. 依次停止接受新连接、处理完在途消息、通知客户端重连、关闭Kafka
 func main() {
 	// 加载配置
 	conf := config.GetConfig()
@@ -42,10 +49,17 @@ func main() {
 		go chat.KafkaChatServer.Start()
 	}
 
+	// 定期解除已到期的群内禁言并通知成员
+	go gorm.GroupInfoService.RunMuteSweeper()
+
 	// 启动HTTPS服务器（异步）
+	srv := &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", host, port),
+		Handler: https_server.GE,
+	}
 	go func() {
 		// Ubuntu22.04云服务器部署
-		if err := https_server.GE.RunTLS(fmt.Sprintf("%s:%d", host, port), "/etc/ssl/certs/server.crt", "/etc/ssl/private/server.key"); err != nil {
+		if err := srv.ListenAndServeTLS("/etc/ssl/certs/server.crt", "/etc/ssl/private/server.key"); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			zlog.Fatal("server running fault")
 			return
 		}
@@ -58,21 +72,28 @@ func main() {
 	// 等待中断信号
 	<-quit
 
-	// 关闭Kafka服务（如果使用Kafka消息模式）
-	if kafkaConfig.MessageMode == "kafka" {
-		kafka.KafkaService.KafkaClose()
-	}
-
-	// 关闭聊天服务器
-	chat.ChatServer.Close()
 	zlog.Info("关闭服务器...")
+	shutdownTimeout := conf.MainConfig.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout*time.Second)
+	defer cancel()
 
-	// 删除所有Redis键，清理缓存
-	if err := myredis.DeleteAllRedisKeys(); err != nil {
+	// 停止接受新的HTTP请求和WebSocket连接，已升级的WebSocket连接不受影响
+	if err := srv.Shutdown(shutdownCtx); err != nil {
 		zlog.Error(err.Error())
-	} else {
-		zlog.Info("所有Redis键已删除")
 	}
 
+	// 处理完在途消息，通知客户端重新连接并等待连接关闭
+	chat.Shutdown(shutdownCtx)
+
+	// 关闭Kafka服务（如果使用Kafka消息模式），写入器关闭前会把缓冲中的消息全部发送出去
+	if kafkaConfig.MessageMode == "kafka" {
+		kafka.KafkaService.KafkaClose()
+	}
+
+	// Redis中的缓存和离线消息由所有节点共享，不随单个节点关闭而清空，缓存依靠过期时间自然淘汰
+
 	zlog.Info("服务器已关闭")
 }
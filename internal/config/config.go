@@ -1,7 +1,9 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/BurntSushi/toml"
@@ -11,6 +13,9 @@ type MainConfig struct {
 	AppName string `toml:"appName"`
 	Host    string `toml:"host"`
 	Port    int    `toml:"port"`
+	NodeId  string `toml:"nodeId"` // 集群中的节点标识，为空时使用主机名和进程号
+	// ShutdownTimeout 优雅关闭的最长等待时间，单位秒
+	ShutdownTimeout time.Duration `toml:"shutdownTimeout"`
 }
 
 type MysqlConfig struct {
@@ -29,10 +34,12 @@ type RedisConfig struct {
 }
 
 type AuthCodeConfig struct {
+	Driver          string `toml:"driver"` // 短信驱动 aliyun / log
 	AccessKeyID     string `toml:"accessKeyID"`
 	AccessKeySecret string `toml:"accessKeySecret"`
-	SignName        string `toml:"signName"`
-	TemplateCode    string `toml:"templateCode"`
+	SignName        string `toml:"signName"`     // 短信签名名称
+	TemplateCode    string `toml:"templateCode"` // 短信模板CODE
+	CodeLogPath     string `toml:"codeLogPath"`  // 日志驱动追加写入验证码的文件，为空时只写入日志
 }
 
 type LogConfig struct {
@@ -41,18 +48,80 @@ type LogConfig struct {
 }
 
 type KafkaConfig struct {
-	MessageMode string        `toml:"messageMode"`
-	HostPort    string        `toml:"hostPort"`
-	LoginTopic  string        `toml:"loginTopic"`
-	LogoutTopic string        `toml:"logoutTopic"`
-	ChatTopic   string        `toml:"chatTopic"`
-	Partition   int           `toml:"partition"`
-	Timeout     time.Duration `toml:"timeout"`
+	MessageMode     string        `toml:"messageMode"`
+	HostPort        string        `toml:"hostPort"`
+	LoginTopic      string        `toml:"loginTopic"`
+	LogoutTopic     string        `toml:"logoutTopic"`
+	ChatTopic       string        `toml:"chatTopic"`
+	DeadLetterTopic string        `toml:"deadLetterTopic"`
+	SessionTopic    string        `toml:"sessionTopic"`
+	Partition       int           `toml:"partition"`
+	Timeout         time.Duration `toml:"timeout"`
+	RequiredAcks    string        `toml:"requiredAcks"`
+	MaxAttempts     int           `toml:"maxAttempts"`
+	RetryBackoffMin time.Duration `toml:"retryBackoffMin"`
+	RetryBackoffMax time.Duration `toml:"retryBackoffMax"`
+}
+
+type ChatConfig struct {
+	SendQueueSize      int           `toml:"sendQueueSize"`      // 每个客户端下行队列的长度
+	SubmitTimeout      time.Duration `toml:"submitTimeout"`      // 上行消息等待进入转发通道的最长时间，单位毫秒
+	SlowConsumerPolicy string        `toml:"slowConsumerPolicy"` // 下行队列已满时的处理策略 drop / disconnect / offline
+	OfflineQueueLimit  int64         `toml:"offlineQueueLimit"`  // 每个用户最多暂存的离线消息条数
+	OfflineExpire      time.Duration `toml:"offlineExpire"`      // 离线消息的保留时间，单位小时
+	WorkerCount        int           `toml:"workerCount"`        // 消息处理worker数量，同一会话的消息由同一个worker处理
+	WorkerQueueSize    int           `toml:"workerQueueSize"`    // 每个worker队列的长度
+	StatsInterval      time.Duration `toml:"statsInterval"`      // 输出流水线队列深度日志的间隔，单位秒，0表示不输出
+	DedupExpire        time.Duration `toml:"dedupExpire"`        // 客户端消息ID去重记录在Redis中的保留时间，单位小时
+	MaxTextLength      int           `toml:"maxTextLength"`      // 文本消息的最大字符数
+	MaxAVDataLength    int           `toml:"maxAVDataLength"`    // 通话信令数据的最大字节数
+	VoiceFormats       []string      `toml:"voiceFormats"`       // 语音消息允许的音频格式，即文件扩展名
+	MaxVoiceDuration   time.Duration `toml:"maxVoiceDuration"`   // 语音消息的最大时长，单位秒
+	CallRingTimeout    time.Duration `toml:"callRingTimeout"`    // 通话振铃的最长时间，超时记为未接听，单位秒
+	MaxCallDuration    time.Duration `toml:"maxCallDuration"`    // 通话的最长时间，超时由服务端结束通话，单位小时
+	MaxGroupCallSize   int           `toml:"maxGroupCallSize"`   // 群通话的最多参与人数
+	BlockPolicy        string        `toml:"blockPolicy"`        // 向拉黑了自己的用户发消息时的处理策略 reject / drop
 }
 
 type StaticSrcConfig struct {
 	StaticAvatarPath string `toml:"staticAvatarPath"`
 	StaticFilePath   string `toml:"staticFilePath"`
+	StaticThumbPath  string `toml:"staticThumbPath"` // 图片缩略图的保存目录
+}
+
+type StorageConfig struct {
+	Driver          string        `toml:"driver"`          // 存储驱动 local / s3
+	Endpoint        string        `toml:"endpoint"`        // S3兼容对象存储的地址，如127.0.0.1:9000
+	AccessKeyID     string        `toml:"accessKeyID"`     // 访问密钥ID
+	SecretAccessKey string        `toml:"secretAccessKey"` // 访问密钥
+	Bucket          string        `toml:"bucket"`          // 保存上传文件的桶，不存在时自动创建
+	Region          string        `toml:"region"`          // 区域，MinIO可以为空
+	UseSSL          bool          `toml:"useSSL"`          // 是否使用https连接对象存储
+	PresignExpire   time.Duration `toml:"presignExpire"`   // 预签名下载地址的有效期，单位分钟
+	SignSecret      string        `toml:"signSecret"`      // 签名下载地址使用的密钥，多节点部署时各节点必须相同
+	SignExpire      time.Duration `toml:"signExpire"`      // 签名下载地址的有效期，单位分钟
+	PartPath        string        `toml:"partPath"`        // 本地驱动保存分片上传中间分片的目录
+}
+
+// UploadLimit 一类文件的上传限制
+type UploadLimit struct {
+	MaxSize   int64    `toml:"maxSize"`   // 最大文件大小，单位MB
+	MimeTypes []string `toml:"mimeTypes"` // 允许的文件类型，根据文件内容识别，支持image/*形式的通配，为空表示不限
+}
+
+type UploadConfig struct {
+	ChunkSize   int64         `toml:"chunkSize"`   // 分片上传的分片大小，单位KB，最后一个分片可以小于该值，未配置时为4096
+	Expire      time.Duration `toml:"expire"`      // 分片上传会话的有效期，单位小时，过期后未完成的上传被丢弃，未配置时为24
+	File        UploadLimit   `toml:"file"`        // 聊天文件的上传限制
+	Avatar      UploadLimit   `toml:"avatar"`      // 头像的上传限制
+	AvatarSizes []int         `toml:"avatarSizes"` // 头像裁剪为正方形后保存的边长，第一个为资料中使用的头像
+}
+
+type IceConfig struct {
+	StunUrls   []string      `toml:"stunUrls"`   // STUN服务器地址，如stun:stun.example.com:3478
+	TurnUrls   []string      `toml:"turnUrls"`   // TURN服务器地址，如turn:turn.example.com:3478?transport=udp
+	TurnSecret string        `toml:"turnSecret"` // 与TURN服务器共享的密钥，即coturn的static-auth-secret，为空时不签发TURN凭证
+	TurnTTL    time.Duration `toml:"turnTTL"`    // TURN临时凭证的有效期，单位小时
 }
 
 type Config struct {
@@ -62,7 +131,11 @@ type Config struct {
 	AuthCodeConfig  `toml:"authCodeConfig"`
 	LogConfig       `toml:"logConfig"`
 	KafkaConfig     `toml:"kafkaConfig"`
+	ChatConfig      `toml:"chatConfig"`
 	StaticSrcConfig `toml:"staticSrcConfig"`
+	StorageConfig   `toml:"storageConfig"`
+	UploadConfig    `toml:"uploadConfig"`
+	IceConfig       `toml:"iceConfig"`
 }
 
 var config *Config
@@ -80,6 +153,18 @@ func GetConfig() *Config {
 	if config == nil {
 		config = new(Config)
 		_ = LoadConfig()
+		if config.NodeId == "" {
+			config.NodeId = defaultNodeId()
+		}
 	}
 	return config
 }
+
+// defaultNodeId 未配置节点标识时，用主机名和进程号区分同一集群中的不同实例
+func defaultNodeId() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "gochat"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
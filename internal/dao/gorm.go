@@ -43,12 +43,20 @@ func init() {
 	// 当数据库中不存在对应表时，会自动创建
 	// 当表结构发生变化时，会自动更新（注意：可能会丢失数据）
 	err = GormDB.AutoMigrate(
-		&model.UserInfo{},     // 用户信息表
-		&model.GroupInfo{},    // 群组信息表
-		&model.UserContact{},  // 用户联系人表
-		&model.Session{},      // 会话表
-		&model.ContactApply{}, // 联系人申请表
-		&model.Message{},      // 消息表
+		&model.UserInfo{},        // 用户信息表
+		&model.GroupInfo{},       // 群组信息表
+		&model.UserContact{},     // 用户联系人表
+		&model.Session{},         // 会话表
+		&model.ContactApply{},    // 联系人申请表
+		&model.Message{},         // 消息表
+		&model.Notification{},    // 系统通知表
+		&model.GroupInvite{},     // 群聊邀请链接表
+		&model.UploadedFile{},    // 上传文件表
+		&model.UploadSession{},   // 分片上传会话表
+		&model.UploadPart{},      // 分片上传分片表
+		&model.CallSession{},     // 音视频通话表
+		&model.GroupCall{},       // 群通话表
+		&model.GroupCallMember{}, // 群通话参与记录表
 	)
 	if err != nil {
 		// 迁移失败，记录致命错误并退出程序
@@ -3,4 +3,8 @@ package request
 type AVData struct {
 	MessageId string `json:"messageId"`
 	Type      string `json:"type"`
+	// CallId 服务端分配的通话ID，发起通话后由服务端填写；客户端未携带时按发送者当前的通话处理
+	CallId string `json:"callId,omitempty"`
+	// TargetId 群通话中SDP、ICE等信令的接收者，群通话为mesh拓扑，信令在两个参与者之间点对点转发
+	TargetId string `json:"targetId,omitempty"`
 }
@@ -13,4 +13,6 @@ type ChatMessageRequest struct {
 	FileType   string `json:"file_type"`
 	FileName   string `json:"file_name"`
 	AVdata     string `json:"av_data"`
+	// ClientMessageId 客户端生成的消息ID，同一发送者重试时保持不变，服务端据此去重
+	ClientMessageId string `json:"client_message_id"`
 }
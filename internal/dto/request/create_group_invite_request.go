@@ -0,0 +1,10 @@
+package request
+
+type CreateGroupInviteRequest struct {
+	GroupId string `json:"group_id"`
+	OwnerId string `json:"owner_id"`
+	// ExpireHours 有效时长，单位小时，0表示永不过期
+	ExpireHours int `json:"expire_hours"`
+	// MaxUses 最多可使用次数，0表示不限
+	MaxUses int `json:"max_uses"`
+}
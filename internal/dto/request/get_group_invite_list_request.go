@@ -0,0 +1,6 @@
+package request
+
+type GetGroupInviteListRequest struct {
+	GroupId string `json:"group_id"`
+	OwnerId string `json:"owner_id"`
+}
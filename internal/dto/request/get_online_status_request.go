@@ -0,0 +1,5 @@
+package request
+
+type GetOnlineStatusRequest struct {
+	UuidList []string `json:"uuid_list"`
+}
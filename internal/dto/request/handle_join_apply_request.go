@@ -0,0 +1,7 @@
+package request
+
+type HandleJoinApplyRequest struct {
+	GroupId     string `json:"group_id"`
+	OwnerId     string `json:"owner_id"`
+	ApplicantId string `json:"applicant_id"`
+}
@@ -0,0 +1,11 @@
+package request
+
+type InitUploadRequest struct {
+	OwnerId  string `json:"owner_id"`
+	FileName string `json:"file_name"`
+	FileSize int64  `json:"file_size"`
+	// Sha256 完整文件的sha256，合并分片后据此校验
+	Sha256 string `json:"sha256"`
+	// Category 文件用途，0.聊天文件，1.头像
+	Category int8 `json:"category"`
+}
@@ -0,0 +1,7 @@
+package request
+
+type InviteGroupMembersRequest struct {
+	GroupId  string   `json:"group_id"`
+	OwnerId  string   `json:"owner_id"`
+	UuidList []string `json:"uuid_list"`
+}
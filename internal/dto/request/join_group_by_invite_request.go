@@ -0,0 +1,6 @@
+package request
+
+type JoinGroupByInviteRequest struct {
+	OwnerId string `json:"owner_id"`
+	Code    string `json:"code"`
+}
@@ -0,0 +1,8 @@
+package request
+
+type MuteGroupMembersRequest struct {
+	GroupId  string   `json:"group_id"`
+	OwnerId  string   `json:"owner_id"`
+	UuidList []string `json:"uuid_list"`
+	Minutes  int      `json:"minutes"` // 禁言时长，单位分钟，0表示永久禁言，直到手动解除
+}
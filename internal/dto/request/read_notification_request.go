@@ -0,0 +1,6 @@
+package request
+
+type ReadNotificationRequest struct {
+	OwnerId  string   `json:"owner_id"`
+	UuidList []string `json:"uuid_list"` // 为空时将全部通知标记为已读
+}
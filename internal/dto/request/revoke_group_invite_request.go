@@ -0,0 +1,6 @@
+package request
+
+type RevokeGroupInviteRequest struct {
+	OwnerId  string `json:"owner_id"`
+	InviteId string `json:"invite_id"`
+}
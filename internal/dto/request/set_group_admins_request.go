@@ -0,0 +1,8 @@
+package request
+
+type SetGroupAdminsRequest struct {
+	GroupId  string   `json:"group_id"`
+	OwnerId  string   `json:"owner_id"`
+	UuidList []string `json:"uuid_list"`
+	IsAdmin  bool     `json:"is_admin"` // true设为管理员，false取消管理员
+}
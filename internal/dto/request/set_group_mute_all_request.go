@@ -0,0 +1,7 @@
+package request
+
+type SetGroupMuteAllRequest struct {
+	GroupId string `json:"group_id"`
+	OwnerId string `json:"owner_id"`
+	MuteAll bool   `json:"mute_all"`
+}
@@ -0,0 +1,10 @@
+package request
+
+// UpdateSessionSettingRequest 更新会话设置，字段为空表示不修改
+type UpdateSessionSettingRequest struct {
+	OwnerId    string `json:"owner_id"`
+	SessionId  string `json:"session_id"`
+	IsMuted    *bool  `json:"is_muted"`
+	IsPinned   *bool  `json:"is_pinned"`
+	IsArchived *bool  `json:"is_archived"`
+}
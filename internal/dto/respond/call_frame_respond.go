@@ -0,0 +1,12 @@
+package respond
+
+// CallFrameRespond WebSocket通话状态帧，frame字段固定为"call"
+// 通话状态变更时下发给主叫和被叫，客户端以此为准更新通话界面
+type CallFrameRespond struct {
+	Frame    string `json:"frame"`
+	CallId   string `json:"call_id"`
+	CallerId string `json:"caller_id"`
+	CalleeId string `json:"callee_id"`
+	Status   int8   `json:"status"`
+	Duration int    `json:"duration"` // 通话时长，单位毫秒，接听前为0
+}
@@ -0,0 +1,10 @@
+package respond
+
+// ChatAckRespond WebSocket消息确认帧，frame字段固定为"ack"
+// 告知发送者客户端消息ID对应的服务端消息ID，Duplicate为true表示是重复提交，消息没有再次保存
+type ChatAckRespond struct {
+	Frame           string `json:"frame"`
+	ClientMessageId string `json:"client_message_id"`
+	Uuid            string `json:"uuid"`
+	Duplicate       bool   `json:"duplicate"`
+}
@@ -0,0 +1,10 @@
+package respond
+
+// ChatErrorRespond WebSocket错误帧，frame字段固定为"error"，用于和普通消息区分
+// ClientMessageId 为被拒绝消息的客户端消息ID，便于前端定位失败的消息
+type ChatErrorRespond struct {
+	Frame           string `json:"frame"`
+	Code            string `json:"code"`
+	Message         string `json:"message"`
+	ClientMessageId string `json:"client_message_id,omitempty"`
+}
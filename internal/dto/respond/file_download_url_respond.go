@@ -0,0 +1,6 @@
+package respond
+
+type FileDownloadUrlRespond struct {
+	Url      string `json:"url"`
+	FileName string `json:"file_name"`
+}
@@ -1,13 +1,15 @@
 package respond
 
 type GetGroupInfoRespond struct {
-	Uuid      string `json:"uuid"`
-	Name      string `json:"name"`
-	Notice    string `json:"notice"`
-	MemberCnt int    `json:"member_cnt"`
-	OwnerId   string `json:"owner_id"`
-	AddMode   int8   `json:"add_mode"`
-	Status    int8   `json:"status"`
-	Avatar    string `json:"avatar"`
-	IsDeleted bool   `json:"is_deleted"`
+	Uuid      string   `json:"uuid"`
+	Name      string   `json:"name"`
+	Notice    string   `json:"notice"`
+	MemberCnt int      `json:"member_cnt"`
+	OwnerId   string   `json:"owner_id"`
+	Admins    []string `json:"admins"`
+	MuteAll   bool     `json:"mute_all"`
+	AddMode   int8     `json:"add_mode"`
+	Status    int8     `json:"status"`
+	Avatar    string   `json:"avatar"`
+	IsDeleted bool     `json:"is_deleted"`
 }
@@ -0,0 +1,7 @@
+package respond
+
+type GetIceServersRespond struct {
+	IceServers []IceServerRespond `json:"ice_servers"`
+	// Ttl TURN凭证的有效期，单位秒，客户端应在过期前重新获取，没有TURN凭证时为0
+	Ttl int64 `json:"ttl"`
+}
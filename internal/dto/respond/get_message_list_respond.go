@@ -11,5 +11,12 @@ type GetMessageListRespond struct {
 	FileType   string `json:"file_type"`
 	FileName   string `json:"file_name"`
 	FileSize   string `json:"file_size"`
-	CreatedAt  string `json:"created_at"` // 先用CreatedAt排序，后面考虑改成SentAt
+	// 图片和音频的媒体信息，历史列表使用缩略图而不是原图
+	Width        int    `json:"width,omitempty"`
+	Height       int    `json:"height,omitempty"`
+	Duration     int    `json:"duration,omitempty"` // 音频或通话时长，单位毫秒
+	ThumbnailUrl string `json:"thumbnail_url,omitempty"`
+	AVdata       string `json:"av_data,omitempty"` // 通话记录的状态和时长，仅通话消息使用
+	CreatedAt    string `json:"created_at"`        // 先用CreatedAt排序，后面考虑改成SentAt
+	Muted        bool   `json:"muted,omitempty"`   // 接收者对该会话开启了免打扰，前端只更新列表不弹出提醒，仅实时推送时使用
 }
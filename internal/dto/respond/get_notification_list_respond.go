@@ -0,0 +1,6 @@
+package respond
+
+type GetNotificationListRespond struct {
+	UnreadCount      int64                 `json:"unread_count"`
+	NotificationList []NotificationRespond `json:"notification_list"`
+}
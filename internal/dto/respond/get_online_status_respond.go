@@ -0,0 +1,6 @@
+package respond
+
+type GetOnlineStatusRespond struct {
+	UserId   string `json:"user_id"`
+	IsOnline bool   `json:"is_online"`
+}
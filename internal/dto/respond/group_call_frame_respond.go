@@ -0,0 +1,15 @@
+package respond
+
+// GroupCallFrameRespond WebSocket群通话帧，frame字段固定为"group_call"
+// 发起和结束时下发给全部群成员，成员加入和离开时下发给房间内的参与者
+type GroupCallFrameRespond struct {
+	Frame        string   `json:"frame"`
+	Event        string   `json:"event"` // start / join / leave / end
+	CallId       string   `json:"call_id"`
+	GroupId      string   `json:"group_id"`
+	StarterId    string   `json:"starter_id"`
+	UserId       string   `json:"user_id"` // 触发本次事件的成员
+	Status       int8     `json:"status"`
+	Participants []string `json:"participants"` // 当前房间内的参与者，mesh拓扑下新加入者向其中每个人发起连接
+	Duration     int      `json:"duration"`     // 通话时长，单位毫秒，仅结束时有值
+}
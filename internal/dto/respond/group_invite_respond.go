@@ -0,0 +1,13 @@
+package respond
+
+type GroupInviteRespond struct {
+	InviteId  string `json:"invite_id"`
+	GroupId   string `json:"group_id"`
+	CreatorId string `json:"creator_id"`
+	Code      string `json:"code"`
+	MaxUses   int    `json:"max_uses"`
+	UsedCount int    `json:"used_count"`
+	ExpireAt  string `json:"expire_at"`
+	Status    int8   `json:"status"`
+	CreatedAt string `json:"created_at"`
+}
@@ -1,8 +1,11 @@
 package respond
 
 type GroupSessionListRespond struct {
-	SessionId string `json:"session_id"`
-	GroupName string `json:"group_name"`
-	GroupId   string `json:"group_id"`
-	Avatar    string `json:"avatar"`
+	SessionId  string `json:"session_id"`
+	GroupName  string `json:"group_name"`
+	GroupId    string `json:"group_id"`
+	Avatar     string `json:"avatar"`
+	IsMuted    bool   `json:"is_muted"`
+	IsPinned   bool   `json:"is_pinned"`
+	IsArchived bool   `json:"is_archived"`
 }
@@ -0,0 +1,8 @@
+package respond
+
+// IceServerRespond 与浏览器RTCIceServer的结构一致，可以直接传给RTCPeerConnection
+type IceServerRespond struct {
+	Urls       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
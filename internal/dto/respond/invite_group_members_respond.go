@@ -0,0 +1,8 @@
+package respond
+
+type InviteGroupMembersRespond struct {
+	// Joined 已直接加入群聊的用户
+	Joined []string `json:"joined"`
+	// Pending 已提交群主和管理员审核的用户
+	Pending []string `json:"pending"`
+}
@@ -0,0 +1,11 @@
+package respond
+
+type JoinApplyRespond struct {
+	ApplyId     string `json:"apply_id"`
+	UserId      string `json:"user_id"`
+	Nickname    string `json:"nickname"`
+	Avatar      string `json:"avatar"`
+	Message     string `json:"message"`
+	Status      int8   `json:"status"`
+	LastApplyAt string `json:"last_apply_at"`
+}
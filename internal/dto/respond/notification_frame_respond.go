@@ -0,0 +1,7 @@
+package respond
+
+// NotificationFrameRespond WebSocket系统通知帧，frame字段固定为"notification"，用于和普通消息区分
+type NotificationFrameRespond struct {
+	Frame        string              `json:"frame"`
+	Notification NotificationRespond `json:"notification"`
+}
@@ -0,0 +1,10 @@
+package respond
+
+type NotificationRespond struct {
+	Uuid      string `json:"uuid"`
+	Type      int8   `json:"type"`
+	Content   string `json:"content"`
+	TargetId  string `json:"target_id"`
+	Status    int8   `json:"status"`
+	CreatedAt string `json:"created_at"`
+}
@@ -0,0 +1,13 @@
+package respond
+
+type PipelineStatsRespond struct {
+	Name          string  `json:"name"`
+	Workers       int     `json:"workers"`
+	QueueSize     int     `json:"queue_size"`
+	QueueDepths   []int   `json:"queue_depths"`
+	TotalDepth    int     `json:"total_depth"`
+	TransmitDepth int     `json:"transmit_depth"`
+	Processed     uint64  `json:"processed"`
+	Failed        uint64  `json:"failed"`
+	AvgWaitMs     float64 `json:"avg_wait_ms"`
+}
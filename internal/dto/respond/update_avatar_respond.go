@@ -0,0 +1,8 @@
+package respond
+
+type UpdateAvatarRespond struct {
+	// Avatar 资料中保存的头像地址，即avatarSizes中第一个边长的头像
+	Avatar string `json:"avatar"`
+	// Sizes 各个边长的头像地址，key为边长
+	Sizes map[int]string `json:"sizes"`
+}
@@ -0,0 +1,20 @@
+package respond
+
+type UploadFileRespond struct {
+	FileId string `json:"file_id"`
+	// Url 文件的固定访问地址，客户端应将该地址放入聊天消息
+	Url string `json:"url"`
+	// DownloadUrl 可以立即下载文件的地址，聊天文件为有时效的签名下载地址，头像使用对象存储时为预签名地址
+	DownloadUrl string `json:"download_url"`
+	FileName    string `json:"file_name"`
+	FileSize    string `json:"file_size"`
+	FileType    string `json:"file_type"`
+	Hash        string `json:"hash"`
+	// 图片和音频的媒体信息，无法解析时为0或空
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Duration     int    `json:"duration"`      // 音频时长，单位毫秒
+	ThumbnailUrl string `json:"thumbnail_url"` // 缩略图的签名下载地址
+	// Duplicate 服务端已有内容相同的文件，本次上传没有写入新文件
+	Duplicate bool `json:"duplicate"`
+}
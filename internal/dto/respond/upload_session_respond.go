@@ -0,0 +1,10 @@
+package respond
+
+type UploadSessionRespond struct {
+	UploadId    string `json:"upload_id"`
+	ChunkSize   int64  `json:"chunk_size"`
+	TotalChunks int    `json:"total_chunks"`
+	// ReceivedParts 已收到的分片序号，续传时跳过这些分片
+	ReceivedParts []int  `json:"received_parts"`
+	ExpireAt      string `json:"expire_at"`
+}
@@ -1,8 +1,11 @@
 package respond
 
 type UserSessionListRespond struct {
-	SessionId string `json:"session_id"`
-	Avatar    string `json:"avatar"`
-	UserId    string `json:"user_id"`
-	Username  string `json:"user_name"`
+	SessionId  string `json:"session_id"`
+	Avatar     string `json:"avatar"`
+	UserId     string `json:"user_id"`
+	Username   string `json:"user_name"`
+	IsMuted    bool   `json:"is_muted"`
+	IsPinned   bool   `json:"is_pinned"`
+	IsArchived bool   `json:"is_archived"`
 }
@@ -2,9 +2,10 @@
 package https_server
 
 import (
-	v1 "gochat/api/v1"       // 导入API v1版本的控制器
-	"gochat/internal/config" // 导入配置管理包
-	"gochat/pkg/ssl"         // 导入SSL/TLS处理包
+	v1 "gochat/api/v1"                // 导入API v1版本的控制器
+	"gochat/internal/config"          // 导入配置管理包
+	"gochat/internal/service/storage" // 导入对象存储包
+	"gochat/pkg/ssl"                  // 导入SSL/TLS处理包
 
 	"github.com/gin-contrib/cors" // Gin框架的CORS中间件
 	"github.com/gin-gonic/gin"    // Gin Web框架
@@ -33,10 +34,13 @@ func init() {
 	GE.Use(ssl.TlsHandler(config.GetConfig().MainConfig.Host, config.GetConfig().MainConfig.Port))
 
 	// 配置静态文件服务
-	// 提供头像文件的静态访问服务
-	GE.Static("/static/avatars", config.GetConfig().StaticSrcConfig.StaticAvatarPath)
-	// 提供其他文件的静态访问服务
-	GE.Static("/static/files", config.GetConfig().StaticSrcConfig.StaticFilePath)
+	if config.GetConfig().StorageConfig.Driver == storage.DriverS3 {
+		// 头像保存在对象存储中，固定访问路径重定向到预签名下载地址
+		GE.GET("/static/*filepath", v1.GetStaticObject)
+	} else {
+		// 提供头像文件的静态访问服务，聊天文件只能通过签名下载地址访问
+		GE.Static("/static/avatars", config.GetConfig().StaticSrcConfig.StaticAvatarPath)
+	}
 
 	// 用户认证相关API路由
 	GE.POST("/login", v1.Login)       // 用户登录
@@ -44,6 +48,7 @@ func init() {
 
 	// 用户信息管理相关API路由
 	GE.POST("/user/updateUserInfo", v1.UpdateUserInfo)   // 更新用户信息
+	GE.POST("/user/updateAvatar", v1.UpdateUserAvatar)   // 更新用户头像
 	GE.POST("/user/getUserInfoList", v1.GetUserInfoList) // 获取用户信息列表
 	GE.POST("/user/ableUsers", v1.AbleUsers)             // 启用用户
 	GE.POST("/user/getUserInfo", v1.GetUserInfo)         // 获取用户信息
@@ -53,6 +58,7 @@ func init() {
 	GE.POST("/user/sendSmsCode", v1.SendSmsCode)         // 发送短信验证码
 	GE.POST("/user/smsLogin", v1.SmsLogin)               // 短信登录
 	GE.POST("/user/wsLogout", v1.WsLogout)               // WebSocket登出
+	GE.POST("/user/getOnlineStatus", v1.GetOnlineStatus) // 获取用户在线状态
 
 	// 群组管理相关API路由
 	GE.POST("/group/createGroup", v1.CreateGroup)               // 创建群组
@@ -66,8 +72,22 @@ func init() {
 	GE.POST("/group/deleteGroups", v1.DeleteGroups)             // 删除群组
 	GE.POST("/group/setGroupsStatus", v1.SetGroupsStatus)       // 设置群组状态
 	GE.POST("/group/updateGroupInfo", v1.UpdateGroupInfo)       // 更新群组信息
+	GE.POST("/group/updateAvatar", v1.UpdateGroupAvatar)        // 更新群组头像
 	GE.POST("/group/getGroupMemberList", v1.GetGroupMemberList) // 获取群组成员列表
 	GE.POST("/group/removeGroupMembers", v1.RemoveGroupMembers) // 移除群组成员
+	GE.POST("/group/setGroupAdmins", v1.SetGroupAdmins)         // 设置群管理员
+	GE.POST("/group/muteGroupMembers", v1.MuteGroupMembers)     // 禁言群成员
+	GE.POST("/group/unmuteGroupMembers", v1.UnmuteGroupMembers) // 解除群成员禁言
+	GE.POST("/group/setGroupMuteAll", v1.SetGroupMuteAll)       // 设置全员禁言
+	GE.POST("/group/getJoinApplyList", v1.GetJoinApplyList)     // 获取加群申请列表
+	GE.POST("/group/passJoinApply", v1.PassJoinApply)           // 通过加群申请
+	GE.POST("/group/refuseJoinApply", v1.RefuseJoinApply)       // 拒绝加群申请
+	GE.POST("/group/blackJoinApply", v1.BlackJoinApply)         // 拉黑加群申请
+	GE.POST("/group/inviteGroupMembers", v1.InviteGroupMembers) // 邀请好友加入群聊
+	GE.POST("/group/createGroupInvite", v1.CreateGroupInvite)   // 创建群聊邀请链接
+	GE.POST("/group/getGroupInviteList", v1.GetGroupInviteList) // 获取群聊邀请链接列表
+	GE.POST("/group/revokeGroupInvite", v1.RevokeGroupInvite)   // 撤销群聊邀请链接
+	GE.POST("/group/joinGroupByInvite", v1.JoinGroupByInvite)   // 通过邀请码加入群聊
 
 	// 会话管理相关API路由
 	GE.POST("/session/openSession", v1.OpenSession)                         // 开启会话
@@ -75,6 +95,7 @@ func init() {
 	GE.POST("/session/getGroupSessionList", v1.GetGroupSessionList)         // 获取群组会话列表
 	GE.POST("/session/deleteSession", v1.DeleteSession)                     // 删除会话
 	GE.POST("/session/checkOpenSessionAllowed", v1.CheckOpenSessionAllowed) // 检查开启会话权限
+	GE.POST("/session/updateSessionSetting", v1.UpdateSessionSetting)       // 更新会话设置
 
 	// 联系人管理相关API路由
 	GE.POST("/contact/getUserList", v1.GetUserList)               // 获取用户列表
@@ -96,9 +117,26 @@ func init() {
 	GE.POST("/message/uploadAvatar", v1.UploadAvatar)               // 上传头像
 	GE.POST("/message/uploadFile", v1.UploadFile)                   // 上传文件
 
+	// 文件上传下载相关API路由
+	GE.POST("/file/getDownloadUrl", v1.GetFileDownloadUrl) // 获取聊天文件的签名下载地址
+	GE.GET(storage.DownloadPath, v1.DownloadFile)          // 通过签名下载地址下载聊天文件
+	GE.POST("/file/initUpload", v1.InitUpload)             // 创建分片上传会话
+	GE.POST("/file/uploadPart", v1.UploadPart)             // 上传分片
+	GE.POST("/file/getUploadStatus", v1.GetUploadStatus)   // 查询分片上传状态
+	GE.POST("/file/completeUpload", v1.CompleteUpload)     // 完成分片上传
+	GE.POST("/file/abortUpload", v1.AbortUpload)           // 取消分片上传
+
+	// 音视频通话相关API路由
+	GE.POST("/call/getIceServers", v1.GetIceServers) // 获取ICE服务器和TURN临时凭证
+
 	// 聊天室相关API路由
 	GE.POST("/chatroom/getCurContactListInChatRoom", v1.GetCurContactListInChatRoom) // 获取聊天室中的联系人列表
 
+	// 系统通知相关API路由
+	GE.POST("/notification/getNotificationList", v1.GetNotificationList) // 获取系统通知列表
+	GE.POST("/notification/readNotification", v1.ReadNotification)       // 标记系统通知已读
+
 	// WebSocket相关API路由
-	GE.GET("/wss", v1.WsLogin) // WebSocket连接入口
+	GE.GET("/wss", v1.WsLogin)                            // WebSocket连接入口
+	GE.GET("/chat/getPipelineStats", v1.GetPipelineStats) // 获取消息处理流水线指标
 }
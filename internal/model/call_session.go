@@ -0,0 +1,22 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+type CallSession struct {
+	Id         int64        `gorm:"column:id;primaryKey;comment:自增id"`
+	Uuid       string       `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:通话uuid"`
+	CallerId   string       `gorm:"column:caller_id;index;type:char(20);not null;comment:主叫uuid"`
+	CalleeId   string       `gorm:"column:callee_id;index;type:char(20);not null;comment:被叫uuid"`
+	Status     int8         `gorm:"column:status;index;default:0;comment:状态，0.振铃中，1.通话中，2.已拒绝，3.未接听，4.已结束，5.忙线"`
+	AnsweredAt sql.NullTime `gorm:"column:answered_at;type:datetime;comment:接听时间"`
+	EndedAt    sql.NullTime `gorm:"column:ended_at;type:datetime;comment:结束时间"`
+	Duration   int          `gorm:"column:duration;default:0;comment:通话时长，从接听到结束，单位毫秒"`
+	CreatedAt  time.Time    `gorm:"column:created_at;type:datetime;not null;comment:发起时间"`
+}
+
+func (CallSession) TableName() string {
+	return "call_session"
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+type GroupCall struct {
+	Id        int64        `gorm:"column:id;primaryKey;comment:自增id"`
+	Uuid      string       `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:群通话uuid"`
+	GroupId   string       `gorm:"column:group_id;index;type:char(20);not null;comment:群聊uuid"`
+	StarterId string       `gorm:"column:starter_id;type:char(20);not null;comment:发起者uuid"`
+	Status    int8         `gorm:"column:status;index;default:0;comment:状态，0.进行中，1.已结束"`
+	EndedAt   sql.NullTime `gorm:"column:ended_at;type:datetime;comment:结束时间"`
+	Duration  int          `gorm:"column:duration;default:0;comment:通话时长，从发起到最后一人离开，单位毫秒"`
+	CreatedAt time.Time    `gorm:"column:created_at;type:datetime;not null;comment:发起时间"`
+}
+
+func (GroupCall) TableName() string {
+	return "group_call"
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// GroupCallMember 群通话的参与记录，每次加入一条记录，离开时填写离开时间
+type GroupCallMember struct {
+	Id       int64        `gorm:"column:id;primaryKey;comment:自增id"`
+	CallId   string       `gorm:"column:call_id;index;type:char(20);not null;comment:群通话uuid"`
+	UserId   string       `gorm:"column:user_id;index;type:char(20);not null;comment:参与者uuid"`
+	JoinedAt time.Time    `gorm:"column:joined_at;type:datetime;not null;comment:加入时间"`
+	LeftAt   sql.NullTime `gorm:"column:left_at;type:datetime;comment:离开时间，为空表示仍在通话中"`
+}
+
+func (GroupCallMember) TableName() string {
+	return "group_call_member"
+}
@@ -15,6 +15,8 @@ type GroupInfo struct {
 	Members   json.RawMessage `gorm:"column:members;type:json;comment:群组成员"`
 	MemberCnt int             `gorm:"column:member_cnt;default:1;comment:群人数"` // 默认群主1人
 	OwnerId   string          `gorm:"column:owner_id;type:char(20);not null;comment:群主uuid"`
+	Admins    json.RawMessage `gorm:"column:admins;type:json;comment:群管理员"`
+	MuteAll   int8            `gorm:"column:mute_all;default:0;comment:全员禁言，0.否，1.是"`
 	AddMode   int8            `gorm:"column:add_mode;default:0;comment:加群方式，0.直接，1.审核"`
 	Avatar    string          `gorm:"column:avatar;type:varchar(255);default:'/static/avatars/default-group-avatar.png';not null;comment:头像"`
 	Status    int8            `gorm:"column:status;default:0;comment:状态，0.正常，1.禁用，2.解散"`
@@ -26,3 +28,63 @@ type GroupInfo struct {
 func (GroupInfo) TableName() string {
 	return "group_info"
 }
+
+// AdminList 解析群管理员列表，旧数据没有管理员字段时返回空列表
+func (g GroupInfo) AdminList() []string {
+	var admins []string
+	if len(g.Admins) > 0 {
+		_ = json.Unmarshal(g.Admins, &admins)
+	}
+	return admins
+}
+
+// IsManager 判断用户是否是群主或群管理员
+func (g GroupInfo) IsManager(userId string) bool {
+	if userId == g.OwnerId {
+		return true
+	}
+	for _, admin := range g.AdminList() {
+		if admin == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveAdmins 从群管理员列表中移除用户，成员退群或被移出群聊时调用，用户不是管理员时不做修改
+func (g *GroupInfo) RemoveAdmins(uuidList ...string) error {
+	removed := make(map[string]bool, len(uuidList))
+	for _, uuid := range uuidList {
+		removed[uuid] = true
+	}
+	admins := g.AdminList()
+	remaining := make([]string, 0, len(admins))
+	for _, admin := range admins {
+		if !removed[admin] {
+			remaining = append(remaining, admin)
+		}
+	}
+	if len(remaining) == len(admins) {
+		return nil
+	}
+	data, err := json.Marshal(remaining)
+	if err != nil {
+		return err
+	}
+	g.Admins = data
+	return nil
+}
+
+// HasMember 判断用户是否在群成员列表中
+func (g GroupInfo) HasMember(userId string) bool {
+	var members []string
+	if err := json.Unmarshal(g.Members, &members); err != nil {
+		return false
+	}
+	for _, member := range members {
+		if member == userId {
+			return true
+		}
+	}
+	return false
+}
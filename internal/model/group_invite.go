@@ -0,0 +1,23 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+type GroupInvite struct {
+	Id        int64        `gorm:"column:id;primaryKey;comment:自增id"`
+	Uuid      string       `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:邀请链接uuid"`
+	GroupId   string       `gorm:"column:group_id;index;type:char(20);not null;comment:群聊uuid"`
+	CreatorId string       `gorm:"column:creator_id;type:char(20);not null;comment:创建者uuid"`
+	Code      string       `gorm:"column:code;uniqueIndex;type:char(16);not null;comment:邀请码"`
+	MaxUses   int          `gorm:"column:max_uses;default:0;comment:最多可使用次数，0表示不限"`
+	UsedCount int          `gorm:"column:used_count;default:0;comment:已使用次数"`
+	ExpireAt  sql.NullTime `gorm:"column:expire_at;comment:过期时间，为空表示永不过期"`
+	Status    int8         `gorm:"column:status;default:0;comment:状态，0.有效，1.已撤销"`
+	CreatedAt time.Time    `gorm:"column:created_at;type:datetime;not null;comment:创建时间"`
+}
+
+func (GroupInvite) TableName() string {
+	return "group_invite"
+}
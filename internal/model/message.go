@@ -12,7 +12,7 @@ type Message struct {
 	Type       int8         `gorm:"column:type;not null;comment:消息类型，0.文本，1.语音，2.文件，3.通话"` // 通话不用存消息内容或者url
 	Content    string       `gorm:"column:content;type:TEXT;comment:消息内容"`
 	Url        string       `gorm:"column:url;type:varchar(255);comment:消息url"`
-	SendId     string       `gorm:"column:send_id;index;type:char(20);not null;comment:发送者uuid"`
+	SendId     string       `gorm:"column:send_id;index;uniqueIndex:idx_send_client_message,priority:1;type:char(20);not null;comment:发送者uuid"`
 	SendName   string       `gorm:"column:send_name;type:varchar(20);not null;comment:发送者昵称"`
 	SendAvatar string       `gorm:"column:send_avatar;type:varchar(255);default:'/static/avatars/default-message-avatar.png';not null;comment:发送者头像"`
 	ReceiveId  string       `gorm:"column:receive_id;index;type:char(20);not null;comment:接受者uuid"`
@@ -23,6 +23,13 @@ type Message struct {
 	CreatedAt  time.Time    `gorm:"column:created_at;not null;comment:创建时间"`
 	SendAt     sql.NullTime `gorm:"column:send_at;comment:发送时间"`
 	AVdata     string       `gorm:"column:av_data;comment:通话传递数据"`
+	// 未携带客户端消息ID的消息存为NULL，不参与唯一索引
+	ClientMessageId sql.NullString `gorm:"column:client_message_id;uniqueIndex:idx_send_client_message,priority:2;type:varchar(64);comment:客户端消息id，与发送者uuid共同去重"`
+	// 以下媒体信息由服务端根据上传记录填写，不使用客户端提供的值
+	Width        int    `gorm:"column:width;default:0;comment:图片宽度"`
+	Height       int    `gorm:"column:height;default:0;comment:图片高度"`
+	Duration     int    `gorm:"column:duration;default:0;comment:音频或通话时长，单位毫秒"`
+	ThumbnailUrl string `gorm:"column:thumbnail_url;type:varchar(255);comment:缩略图的固定访问路径"`
 }
 
 func (Message) TableName() string {
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Notification struct {
+	Id        int64        `gorm:"column:id;primaryKey;comment:自增id"`
+	Uuid      string       `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:通知uuid"`
+	UserId    string       `gorm:"column:user_id;index;type:char(20);not null;comment:接收通知的用户uuid"`
+	Type      int8         `gorm:"column:type;not null;comment:通知类型，0.收到申请，1.申请通过，2.被移出群聊，3.群聊解散，4.账号被禁用，5.群内禁言，6.解除禁言，7.全员禁言，8.群管理员变更，9.申请被拒绝，10.群聊邀请"`
+	Content   string       `gorm:"column:content;type:varchar(255);not null;comment:通知内容"`
+	TargetId  string       `gorm:"column:target_id;type:char(20);comment:通知涉及的用户或群聊uuid"`
+	Status    int8         `gorm:"column:status;not null;comment:状态，0.未读，1.已读"`
+	CreatedAt time.Time    `gorm:"column:created_at;index;not null;comment:创建时间"`
+	ReadAt    sql.NullTime `gorm:"column:read_at;comment:阅读时间"`
+}
+
+func (Notification) TableName() string {
+	return "notification"
+}
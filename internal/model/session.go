@@ -18,6 +18,10 @@ type Session struct {
 	Avatar        string         `gorm:"column:avatar;type:varchar(255);default:'/static/avatars/default-session-avatar.png';not null;comment:头像"`
 	LastMessage   string         `gorm:"column:last_message;type:TEXT;comment:最新的消息"`
 	LastMessageAt sql.NullTime   `gorm:"column:last_message_at;type:datetime;comment:最近接收时间"`
+	IsMuted       int8           `gorm:"column:is_muted;default:0;comment:免打扰，0.否，1.是"`
+	IsPinned      int8           `gorm:"column:is_pinned;default:0;comment:置顶，0.否，1.是"`
+	PinnedAt      sql.NullTime   `gorm:"column:pinned_at;type:datetime;comment:置顶时间，越晚置顶越靠前"`
+	IsArchived    int8           `gorm:"column:is_archived;default:0;comment:归档，0.否，1.是"`
 	CreatedAt     time.Time      `gorm:"column:created_at;Index;type:datetime;comment:创建时间"`
 	DeletedAt     gorm.DeletedAt `gorm:"column:deleted_at;Index;type:datetime;comment:删除时间"`
 }
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+type UploadPart struct {
+	Id        int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	UploadId  string    `gorm:"column:upload_id;uniqueIndex:idx_upload_part,priority:1;type:char(20);not null;comment:上传会话uuid"`
+	PartIndex int       `gorm:"column:part_index;uniqueIndex:idx_upload_part,priority:2;not null;comment:分片序号，从0开始"`
+	Size      int64     `gorm:"column:size;not null;comment:分片大小，单位字节"`
+	Sha256    string    `gorm:"column:sha256;type:char(64);not null;comment:分片的sha256"`
+	CreatedAt time.Time `gorm:"column:created_at;type:datetime;not null;comment:上传时间"`
+}
+
+func (UploadPart) TableName() string {
+	return "upload_part"
+}
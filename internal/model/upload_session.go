@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+type UploadSession struct {
+	Id          int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	Uuid        string    `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:上传会话uuid"`
+	OwnerId     string    `gorm:"column:owner_id;index;type:char(20);not null;comment:上传者uuid"`
+	Category    int8      `gorm:"column:category;not null;comment:文件用途，0.聊天文件，1.头像"`
+	FileName    string    `gorm:"column:file_name;type:varchar(255);comment:清洗后的原始文件名"`
+	TotalSize   int64     `gorm:"column:total_size;not null;comment:文件总大小，单位字节"`
+	ChunkSize   int64     `gorm:"column:chunk_size;not null;comment:分片大小，单位字节"`
+	TotalChunks int       `gorm:"column:total_chunks;not null;comment:分片数量"`
+	Sha256      string    `gorm:"column:sha256;index;type:char(64);not null;comment:客户端声明的完整文件sha256"`
+	Status      int8      `gorm:"column:status;not null;comment:状态，0.上传中，1.已完成，2.已取消"`
+	ExpireAt    time.Time `gorm:"column:expire_at;type:datetime;not null;comment:过期时间"`
+	CreatedAt   time.Time `gorm:"column:created_at;type:datetime;not null;comment:创建时间"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;type:datetime;not null;comment:更新时间"`
+}
+
+func (UploadSession) TableName() string {
+	return "upload_session"
+}
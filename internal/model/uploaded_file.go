@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+type UploadedFile struct {
+	Id           int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	Uuid         string    `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:上传记录uuid"`
+	Hash         string    `gorm:"column:hash;index;type:char(64);not null;comment:文件内容的sha256"`
+	Category     int8      `gorm:"column:category;not null;comment:文件用途，0.聊天文件，1.头像"`
+	StorageName  string    `gorm:"column:storage_name;type:varchar(100);not null;comment:服务端保存的文件名，由内容哈希和扩展名组成"`
+	OriginalName string    `gorm:"column:original_name;type:varchar(255);comment:清洗后的原始文件名"`
+	ContentType  string    `gorm:"column:content_type;type:varchar(100);comment:根据文件内容识别的类型"`
+	Size         int64     `gorm:"column:size;not null;comment:文件大小，单位字节"`
+	Width        int       `gorm:"column:width;default:0;comment:图片宽度，非图片为0"`
+	Height       int       `gorm:"column:height;default:0;comment:图片高度，非图片为0"`
+	Duration     int       `gorm:"column:duration;default:0;comment:音频时长，单位毫秒，非音频为0"`
+	ThumbnailUrl string    `gorm:"column:thumbnail_url;type:varchar(255);comment:缩略图的固定访问路径"`
+	UploaderId   string    `gorm:"column:uploader_id;index;type:char(20);comment:上传者uuid"`
+	CreatedAt    time.Time `gorm:"column:created_at;type:datetime;not null;comment:上传时间"`
+}
+
+func (UploadedFile) TableName() string {
+	return "uploaded_file"
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"database/sql"
 	"time"
 
 	"gorm.io/gorm"
@@ -12,6 +13,7 @@ type UserContact struct {
 	ContactId   string         `gorm:"column:contact_id;index;type:char(20);not null;comment:对应联系id"`
 	ContactType int8           `gorm:"column:contact_type;not null;comment:联系类型，0.用户，1.群聊"`
 	Status      int8           `gorm:"column:status;not null;comment:联系状态，0.正常，1.拉黑，2.被拉黑，3.删除好友，4.被删除好友，5.被禁言，6.退出群聊，7.被踢出群聊"`
+	MuteUntil   sql.NullTime   `gorm:"column:mute_until;type:datetime;comment:群内禁言截止时间，为空且状态为被禁言时表示永久禁言"`
 	CreatedAt   time.Time      `gorm:"column:created_at;type:datetime;not null;comment:创建时间"`
 	UpdatedAt   time.Time      `gorm:"column:updated_at;type:datetime;not null;comment:更新时间"`
 	DeletedAt   gorm.DeletedAt `gorm:"column:deleted_at;type:datetime;index;comment:删除时间"`
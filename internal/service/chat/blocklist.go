@@ -0,0 +1,72 @@
+package chat
+
+import (
+	"gochat/internal/config"
+	"gochat/internal/dao"
+	"gochat/internal/model"
+	"gochat/pkg/enum/contact/contact_status_enum"
+	"gochat/pkg/enum/message/frame_error_enum"
+	"gochat/pkg/zlog"
+)
+
+// 拉黑策略，向拉黑了自己的用户发送私聊消息时的处理方式
+const (
+	blockPolicyReject = "reject" // 不保存不投递，向发送者回送错误帧
+	blockPolicyDrop   = "drop"   // 不保存不投递，照常向发送者回送确认和回显，发送者无法感知被拉黑
+)
+
+// blockedFrameMessage 消息因被拉黑而被拒绝时的提示
+const blockedFrameMessage = "对方已将你拉黑，消息未送达"
+
+// isBlockedBy 判断receiveId是否拉黑了sendId
+// 以投递时数据库中的状态为准，建立会话之后才拉黑的情况同样生效；查询失败时按未拉黑处理，不影响正常消息
+func isBlockedBy(receiveId string, sendId string) bool {
+	var count int64
+	if res := dao.GormDB.Model(&model.UserContact{}).
+		Where("user_id = ? AND contact_id = ? AND status = ?", receiveId, sendId, contact_status_enum.BLACK).
+		Count(&count); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return false
+	}
+	return count > 0
+}
+
+// excludeBlockers 从群成员中去掉拉黑了发送者的成员，被拉黑用户的群消息对拉黑者不可见
+func excludeBlockers(sendId string, members []string) []string {
+	var blockers []string
+	if res := dao.GormDB.Model(&model.UserContact{}).
+		Where("contact_id = ? AND status = ? AND user_id IN (?)", sendId, contact_status_enum.BLACK, members).
+		Pluck("user_id", &blockers); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return members
+	}
+	if len(blockers) == 0 {
+		return members
+	}
+	blocked := make(map[string]struct{}, len(blockers))
+	for _, blocker := range blockers {
+		blocked[blocker] = struct{}{}
+	}
+	visible := make([]string, 0, len(members))
+	for _, member := range members {
+		if _, ok := blocked[member]; !ok {
+			visible = append(visible, member)
+		}
+	}
+	return visible
+}
+
+// handleBlocked 按拉黑策略处理发给拉黑者的私聊消息
+// echo为drop策略下回显给发送者的消息，为nil时不回显（如通话信令）
+func handleBlocked(clients *ClientRegistry, message *model.Message, echo []byte) {
+	zlog.Info("用户" + message.ReceiveId + "已拉黑" + message.SendId + "，消息未投递")
+	if config.GetConfig().ChatConfig.BlockPolicy == blockPolicyDrop {
+		sendAck(clients, message, message.Uuid, false)
+		if echo != nil {
+			// 消息没有保存，回显时不携带消息ID，写goroutine不会去更新消息状态
+			clients.Send(message.SendId, &MessageBack{Message: echo})
+		}
+		return
+	}
+	sendReject(clients, message, reject(frame_error_enum.BLOCKED, blockedFrameMessage))
+}
@@ -0,0 +1,529 @@
+package chat
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gochat/internal/config"
+	"gochat/internal/dao"
+	"gochat/internal/dto/request"
+	"gochat/internal/dto/respond"
+	"gochat/internal/model"
+	myredis "gochat/internal/service/redis"
+	"gochat/pkg/enum/call/call_status_enum"
+	"gochat/pkg/enum/call/group_call_status_enum"
+	"gochat/pkg/enum/message/frame_error_enum"
+	"gochat/pkg/enum/message/message_status_enum"
+	"gochat/pkg/enum/message/message_type_enum"
+	"gochat/pkg/util/random"
+	"gochat/pkg/zlog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 改变通话状态的信令类型，其余类型（如offer、answer、candidate）只在通话双方之间转发
+const (
+	callSignalStart  = "start_call"   // 主叫发起通话
+	callSignalAccept = "receive_call" // 被叫接听
+	callSignalReject = "reject_call"  // 被叫拒绝
+	callSignalHangup = "end_call"     // 任意一方挂断
+)
+
+// isCallEndSignal 判断信令是否用于拒绝、挂断或离开通话
+// 这类信令不受禁言和拉黑限制，否则通话线路要等到超过最长通话时间才会释放
+func isCallEndSignal(signal string) bool {
+	switch signal {
+	case callSignalReject, callSignalHangup, groupCallSignalLeave:
+		return true
+	}
+	return false
+}
+
+// callSweepInterval 检查振铃超时和超长通话的间隔
+const callSweepInterval = 5 * time.Second
+
+// callTransitions 通话状态允许的转换，结束状态不能再转换
+var callTransitions = map[int8][]int8{
+	call_status_enum.RINGING:  {call_status_enum.ACCEPTED, call_status_enum.REJECTED, call_status_enum.MISSED},
+	call_status_enum.ACCEPTED: {call_status_enum.ENDED},
+}
+
+// canTransit 判断通话能否从from转换为to
+func canTransit(from int8, to int8) bool {
+	for _, status := range callTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// 未配置或配置为0时使用的默认值
+const (
+	defaultCallRingTimeout = 60 // 通话振铃的最长时间，单位秒
+	defaultMaxCallDuration = 4  // 通话的最长时间，单位小时
+)
+
+// callRingTimeout 通话振铃的最长时间，超时记为未接听
+func callRingTimeout() time.Duration {
+	if timeout := config.GetConfig().ChatConfig.CallRingTimeout; timeout > 0 {
+		return timeout * time.Second
+	}
+	return defaultCallRingTimeout * time.Second
+}
+
+// maxCallDuration 通话的最长时间，超时由服务端结束通话
+func maxCallDuration() time.Duration {
+	if duration := config.GetConfig().ChatConfig.MaxCallDuration; duration > 0 {
+		return duration * time.Hour
+	}
+	return defaultMaxCallDuration * time.Hour
+}
+
+// callLineKey 用户当前通话的Redis键，值为通话ID，用于判断忙线
+func callLineKey(userId string) string {
+	return "call_line_" + userId
+}
+
+// claimCallLine 占用用户的通话线路，用户已在其他通话中时返回false
+// 线路在通话结束时释放，过期时间只是进程异常退出时的兜底
+func claimCallLine(userId string, callId string) (bool, error) {
+	return myredis.SetKeyNX(callLineKey(userId), callId, callRingTimeout()+maxCallDuration())
+}
+
+// releaseCallLine 释放用户的通话线路，只释放该通话占用的线路
+func releaseCallLine(userId string, callId string) {
+	if err := myredis.DelKeyIfValue(callLineKey(userId), callId); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// handleAVMessage 处理音视频通话信令
+// 发起、接听、拒绝和挂断信令驱动服务端的通话状态机，其余信令只在进行中的通话双方之间转发
+// 通话信令不保存，通话结束时写入一条通话记录
+func handleAVMessage(clients *ClientRegistry, chatMessageReq request.ChatMessageRequest) error {
+	var avData request.AVData
+	if err := json.Unmarshal([]byte(chatMessageReq.AVdata), &avData); err != nil {
+		return errors.New("音视频数据解析失败：" + err.Error())
+	}
+
+	message := newMessage(chatMessageReq)
+	if message.ReceiveId[0] == 'G' {
+		return handleGroupAVMessage(clients, &message, &avData)
+	}
+	// 被拉黑后不能再向对方发起通话，通话信令不回显；挂断和拒绝不受影响，保证进行中的通话能结束
+	if !isCallEndSignal(avData.Type) && isBlockedBy(message.ReceiveId, message.SendId) {
+		handleBlocked(clients, &message, nil)
+		return nil
+	}
+
+	if avData.Type == callSignalStart {
+		return startCall(clients, &message, &avData)
+	}
+
+	call, err := loadActiveCall(avData.CallId, message.SendId)
+	if err != nil {
+		return err
+	}
+	if call == nil || (call.CallerId != message.ReceiveId && call.CalleeId != message.ReceiveId) {
+		sendReject(clients, &message, reject(frame_error_enum.CALL_INVALID, "通话不存在或已结束"))
+		return nil
+	}
+	avData.CallId = call.Uuid
+
+	switch avData.Type {
+	case callSignalAccept, callSignalReject, callSignalHangup:
+		to, ok := signalTarget(call, message.SendId, avData.Type)
+		if !ok || !canTransit(call.Status, to) {
+			sendReject(clients, &message, reject(frame_error_enum.CALL_INVALID, "当前通话状态不允许该操作"))
+			return nil
+		}
+		changed, err := transitCall(call, to)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			// 状态已被对方的信令或超时检查改变
+			sendReject(clients, &message, reject(frame_error_enum.CALL_INVALID, "当前通话状态不允许该操作"))
+			return nil
+		}
+		forwardAVMessage(clients, &message, &avData, message.ReceiveId)
+		if to == call_status_enum.ACCEPTED {
+			pushCallFrame(call)
+		} else {
+			finishCall(call)
+		}
+	default:
+		forwardAVMessage(clients, &message, &avData, message.ReceiveId)
+	}
+	return nil
+}
+
+// startCall 发起通话
+// 主叫已在通话中时拒绝；被叫忙线或不在线时直接结束通话并写入通话记录；否则创建振铃中的通话并转发给被叫
+func startCall(clients *ClientRegistry, message *model.Message, avData *request.AVData) error {
+	call := &model.CallSession{
+		Uuid:      fmt.Sprintf("C%s", random.GetNowAndLenRandomString(11)), // 生成唯一通话ID，以'C'开头
+		CallerId:  message.SendId,
+		CalleeId:  message.ReceiveId,
+		Status:    call_status_enum.RINGING,
+		CreatedAt: time.Now(),
+	}
+
+	claimed, err := claimCallLine(call.CallerId, call.Uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+		sendReject(clients, message, reject(frame_error_enum.SEND_FAILED, "发起通话失败，请稍后重试"))
+		return nil
+	}
+	if !claimed {
+		sendReject(clients, message, reject(frame_error_enum.CALL_BUSY, "你正在通话中，无法发起新的通话"))
+		return nil
+	}
+
+	// 被叫忙线或不在线时通话直接结束
+	switch claimed, err = claimCallLine(call.CalleeId, call.Uuid); {
+	case err != nil:
+		zlog.Error(err.Error())
+		releaseCallLine(call.CallerId, call.Uuid)
+		sendReject(clients, message, reject(frame_error_enum.SEND_FAILED, "发起通话失败，请稍后重试"))
+		return nil
+	case !claimed:
+		call.Status = call_status_enum.BUSY
+	case !onlinePresence.isOnline(call.CalleeId):
+		call.Status = call_status_enum.MISSED
+	}
+	if call.Status != call_status_enum.RINGING {
+		call.EndedAt = sql.NullTime{Time: call.CreatedAt, Valid: true}
+	}
+
+	if res := dao.GormDB.Create(call); res.Error != nil {
+		releaseCallLine(call.CallerId, call.Uuid)
+		releaseCallLine(call.CalleeId, call.Uuid)
+		return errors.New("通话保存失败：" + res.Error.Error())
+	}
+	if call.Status != call_status_enum.RINGING {
+		finishCall(call)
+		return nil
+	}
+
+	avData.CallId = call.Uuid
+	forwardAVMessage(clients, message, avData, message.ReceiveId)
+	// 主叫通过通话状态帧获得通话ID
+	pushCallFrame(call)
+	return nil
+}
+
+// loadActiveCall 查询进行中的通话，callId为空时取发送者当前占用线路的通话
+// 通话不存在、已结束或发送者不是通话的参与者时返回nil
+func loadActiveCall(callId string, senderId string) (*model.CallSession, error) {
+	if callId == "" {
+		lineCallId, err := myredis.GetKey(callLineKey(senderId))
+		if err != nil {
+			return nil, err
+		}
+		if lineCallId == "" {
+			return nil, nil
+		}
+		callId = lineCallId
+	}
+	var call model.CallSession
+	if res := dao.GormDB.First(&call, "uuid = ?", callId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, errors.New("通话查询失败：" + res.Error.Error())
+	}
+	if call.CallerId != senderId && call.CalleeId != senderId {
+		return nil, nil
+	}
+	if len(callTransitions[call.Status]) == 0 {
+		return nil, nil
+	}
+	return &call, nil
+}
+
+// signalTarget 计算信令要把通话转换到的状态
+// 只有被叫可以接听和拒绝；振铃时主叫挂断记为未接听，被叫挂断记为拒绝；通话中任意一方挂断记为结束
+func signalTarget(call *model.CallSession, senderId string, signal string) (int8, bool) {
+	isCallee := senderId == call.CalleeId
+	switch signal {
+	case callSignalAccept:
+		return call_status_enum.ACCEPTED, isCallee
+	case callSignalReject:
+		return call_status_enum.REJECTED, isCallee
+	case callSignalHangup:
+		if call.Status == call_status_enum.ACCEPTED {
+			return call_status_enum.ENDED, true
+		}
+		if isCallee {
+			return call_status_enum.REJECTED, true
+		}
+		return call_status_enum.MISSED, true
+	}
+	return 0, false
+}
+
+// transitCall 以条件更新的方式转换通话状态，多个节点同时处理同一通话时只有一个能成功
+// 返回false表示通话状态已被改变，本次转换没有生效
+func transitCall(call *model.CallSession, to int8) (bool, error) {
+	now := time.Now()
+	updates := map[string]interface{}{"status": to}
+	if to == call_status_enum.ACCEPTED {
+		updates["answered_at"] = now
+	} else {
+		updates["ended_at"] = now
+		if call.AnsweredAt.Valid {
+			updates["duration"] = int(now.Sub(call.AnsweredAt.Time).Milliseconds())
+		}
+	}
+	res := dao.GormDB.Model(&model.CallSession{}).Where("uuid = ? AND status = ?", call.Uuid, call.Status).Updates(updates)
+	if res.Error != nil {
+		return false, errors.New("通话状态更新失败：" + res.Error.Error())
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+
+	call.Status = to
+	if to == call_status_enum.ACCEPTED {
+		call.AnsweredAt = sql.NullTime{Time: now, Valid: true}
+	} else {
+		call.EndedAt = sql.NullTime{Time: now, Valid: true}
+		if duration, ok := updates["duration"].(int); ok {
+			call.Duration = duration
+		}
+	}
+	return true, nil
+}
+
+// forwardAVMessage 将通话信令转发给toId，通话信令不回显给发送者，否则会出现重复的通话请求
+// 私聊通话转发给对方，群通话转发给信令指定的参与者
+func forwardAVMessage(clients *ClientRegistry, message *model.Message, avData *request.AVData, toId string) {
+	avDataByte, err := json.Marshal(avData)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	messageRsp := respond.AVMessageRespond{
+		SendId:     message.SendId,
+		SendName:   message.SendName,
+		SendAvatar: message.SendAvatar,
+		ReceiveId:  message.ReceiveId,
+		Type:       message.Type,
+		CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+		AVdata:     string(avDataByte),
+	}
+	jsonMessage, err := json.Marshal(messageRsp)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	clients.Send(toId, &MessageBack{Message: jsonMessage})
+}
+
+// pushCallFrame 向通话双方下发通话状态帧
+func pushCallFrame(call *model.CallSession) {
+	frame, err := json.Marshal(respond.CallFrameRespond{
+		Frame:    "call",
+		CallId:   call.Uuid,
+		CallerId: call.CallerId,
+		CalleeId: call.CalleeId,
+		Status:   call.Status,
+		Duration: call.Duration,
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	pushFrame(call.CallerId, frame)
+	pushFrame(call.CalleeId, frame)
+}
+
+// finishCall 通话结束后释放双方线路，下发通话状态帧，并写入通话记录
+func finishCall(call *model.CallSession) {
+	releaseCallLine(call.CallerId, call.Uuid)
+	releaseCallLine(call.CalleeId, call.Uuid)
+	pushCallFrame(call)
+	if err := saveCallLog(call); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// callLog 通话记录消息中的通话数据
+type callLog struct {
+	CallId   string `json:"callId"`
+	Type     string `json:"type"`   // 固定为"call_log"
+	Status   int8   `json:"status"` // 私聊为call_status_enum，群聊为group_call_status_enum
+	Duration int    `json:"duration"`
+}
+
+// formatCallDuration 将毫秒时长格式化为"分:秒"
+func formatCallDuration(duration int) string {
+	seconds := duration / 1000
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+// callLogContent 通话记录在消息列表中显示的内容
+func callLogContent(call *model.CallSession) string {
+	switch call.Status {
+	case call_status_enum.ENDED:
+		return "通话时长 " + formatCallDuration(call.Duration)
+	case call_status_enum.REJECTED:
+		return "已拒绝"
+	case call_status_enum.BUSY:
+		return "对方忙线中"
+	default:
+		return "未接听"
+	}
+}
+
+// saveCallLog 以主叫为发送者写入一条通话记录消息，并推送给通话双方
+func saveCallLog(call *model.CallSession) error {
+	var caller model.UserInfo
+	if res := dao.GormDB.Select("uuid", "nickname", "avatar").First(&caller, "uuid = ?", call.CallerId); res.Error != nil {
+		return errors.New("主叫查询失败：" + res.Error.Error())
+	}
+	avData, err := json.Marshal(callLog{CallId: call.Uuid, Type: "call_log", Status: call.Status, Duration: call.Duration})
+	if err != nil {
+		return err
+	}
+	message := model.Message{
+		Uuid:       fmt.Sprintf("M%s", random.GetNowAndLenRandomString(11)),
+		Type:       message_type_enum.AudioOrVideo,
+		Content:    callLogContent(call),
+		SendId:     caller.Uuid,
+		SendName:   caller.Nickname,
+		SendAvatar: caller.Avatar,
+		ReceiveId:  call.CalleeId,
+		FileSize:   "0B",
+		Status:     message_status_enum.Sent, // 通话记录由服务端生成，不跟踪投递状态
+		CreatedAt:  time.Now(),
+		Duration:   call.Duration,
+		AVdata:     string(avData),
+	}
+	if res := dao.GormDB.Create(&message); res.Error != nil {
+		return errors.New("通话记录保存失败：" + res.Error.Error())
+	}
+
+	messageRsp := respond.GetMessageListRespond{
+		SendId:     message.SendId,
+		SendName:   message.SendName,
+		SendAvatar: message.SendAvatar,
+		ReceiveId:  message.ReceiveId,
+		Type:       message.Type,
+		Content:    message.Content,
+		FileSize:   message.FileSize,
+		CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+
+		Duration: message.Duration,
+		AVdata:   message.AVdata,
+	}
+	jsonMessage, err := json.Marshal(messageRsp)
+	if err != nil {
+		return err
+	}
+	pushFrame(call.CallerId, jsonMessage)
+	pushFrame(call.CalleeId, jsonMessage)
+	// 通话记录对双方都可见，两个方向的消息列表缓存都需要更新
+	appendToListCache("message_list_"+call.CallerId+"_"+call.CalleeId, messageRsp)
+	appendToListCache("message_list_"+call.CalleeId+"_"+call.CallerId, messageRsp)
+	return nil
+}
+
+// endUserCalls 用户的连接断开后挂断其进行中的私聊通话，并离开所在的群通话
+// 否则通话双方会一直占用线路显示忙线，直到超过最长通话时间被定期检查结束
+func endUserCalls(userId string) {
+	defer func() {
+		if r := recover(); r != nil {
+			zlog.Error(fmt.Sprintf("end user calls panic: %v", r))
+		}
+	}()
+	var callList []model.CallSession
+	if res := dao.GormDB.Where("(caller_id = ? OR callee_id = ?) AND status IN ?", userId, userId,
+		[]int8{call_status_enum.RINGING, call_status_enum.ACCEPTED}).Find(&callList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+	}
+	for i := range callList {
+		call := &callList[i]
+		to, _ := signalTarget(call, userId, callSignalHangup)
+		changed, err := transitCall(call, to)
+		if err != nil {
+			zlog.Error(err.Error())
+			continue
+		}
+		if changed {
+			finishCall(call)
+		}
+	}
+
+	var roomList []model.GroupCall
+	if res := dao.GormDB.Where("status = ? AND uuid IN (?)", group_call_status_enum.ACTIVE,
+		dao.GormDB.Model(&model.GroupCallMember{}).Select("call_id").Where("user_id = ? AND left_at IS NULL", userId)).
+		Find(&roomList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return
+	}
+	for i := range roomList {
+		if _, err := removeGroupCallParticipant(&roomList[i], userId); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+}
+
+// runCallSweeper 定期结束振铃超时和超过最长时间的通话，包括群通话
+// kafka模式下每个节点都会运行，通话状态以条件更新转换，同一通话只会被结束一次
+func runCallSweeper() {
+	ticker := time.NewTicker(callSweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		sweepCalls()
+	}
+}
+
+// sweepCalls 将振铃超时的通话记为未接听，将超过最长时间的通话结束
+func sweepCalls() {
+	defer func() {
+		if r := recover(); r != nil {
+			zlog.Error(fmt.Sprintf("call sweeper panic: %v", r))
+		}
+	}()
+	now := time.Now()
+	expired := map[int8]*gorm.DB{
+		call_status_enum.MISSED: dao.GormDB.Where("status = ? AND created_at < ?", call_status_enum.RINGING, now.Add(-callRingTimeout())),
+		call_status_enum.ENDED:  dao.GormDB.Where("status = ? AND answered_at < ?", call_status_enum.ACCEPTED, now.Add(-maxCallDuration())),
+	}
+	for to, query := range expired {
+		var callList []model.CallSession
+		if res := query.Find(&callList); res.Error != nil {
+			zlog.Error(res.Error.Error())
+			continue
+		}
+		for i := range callList {
+			call := &callList[i]
+			changed, err := transitCall(call, to)
+			if err != nil {
+				zlog.Error(err.Error())
+				continue
+			}
+			if changed {
+				finishCall(call)
+			}
+		}
+	}
+
+	// 客户端异常退出没有离开的群通话
+	var roomList []model.GroupCall
+	if res := dao.GormDB.Where("status = ? AND created_at < ?", group_call_status_enum.ACTIVE, now.Add(-maxCallDuration())).
+		Find(&roomList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return
+	}
+	for i := range roomList {
+		if err := endGroupCall(&roomList[i]); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+}
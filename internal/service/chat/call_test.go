@@ -0,0 +1,65 @@
+package chat
+
+import (
+	"gochat/internal/model"
+	"gochat/pkg/enum/call/call_status_enum"
+	"testing"
+)
+
+func TestCanTransit(t *testing.T) {
+	tests := []struct {
+		name string
+		from int8
+		to   int8
+		want bool
+	}{
+		{name: "ringing to accepted", from: call_status_enum.RINGING, to: call_status_enum.ACCEPTED, want: true},
+		{name: "ringing to rejected", from: call_status_enum.RINGING, to: call_status_enum.REJECTED, want: true},
+		{name: "ringing to missed", from: call_status_enum.RINGING, to: call_status_enum.MISSED, want: true},
+		{name: "ringing to ended", from: call_status_enum.RINGING, to: call_status_enum.ENDED, want: false},
+		{name: "accepted to ended", from: call_status_enum.ACCEPTED, to: call_status_enum.ENDED, want: true},
+		{name: "accepted to rejected", from: call_status_enum.ACCEPTED, to: call_status_enum.REJECTED, want: false},
+		{name: "accepted to ringing", from: call_status_enum.ACCEPTED, to: call_status_enum.RINGING, want: false},
+		{name: "ended is final", from: call_status_enum.ENDED, to: call_status_enum.ACCEPTED, want: false},
+		{name: "rejected is final", from: call_status_enum.REJECTED, to: call_status_enum.ENDED, want: false},
+		{name: "busy is final", from: call_status_enum.BUSY, to: call_status_enum.RINGING, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canTransit(tt.from, tt.to); got != tt.want {
+				t.Errorf("canTransit(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignalTarget(t *testing.T) {
+	const caller, callee = "U0000000001", "U0000000002"
+	tests := []struct {
+		name   string
+		status int8
+		sender string
+		signal string
+		want   int8
+		wantOk bool
+	}{
+		{name: "callee accepts", status: call_status_enum.RINGING, sender: callee, signal: callSignalAccept, want: call_status_enum.ACCEPTED, wantOk: true},
+		{name: "caller cannot accept", status: call_status_enum.RINGING, sender: caller, signal: callSignalAccept, want: call_status_enum.ACCEPTED, wantOk: false},
+		{name: "callee rejects", status: call_status_enum.RINGING, sender: callee, signal: callSignalReject, want: call_status_enum.REJECTED, wantOk: true},
+		{name: "caller cannot reject", status: call_status_enum.RINGING, sender: caller, signal: callSignalReject, want: call_status_enum.REJECTED, wantOk: false},
+		{name: "caller hangs up while ringing", status: call_status_enum.RINGING, sender: caller, signal: callSignalHangup, want: call_status_enum.MISSED, wantOk: true},
+		{name: "callee hangs up while ringing", status: call_status_enum.RINGING, sender: callee, signal: callSignalHangup, want: call_status_enum.REJECTED, wantOk: true},
+		{name: "caller hangs up after answer", status: call_status_enum.ACCEPTED, sender: caller, signal: callSignalHangup, want: call_status_enum.ENDED, wantOk: true},
+		{name: "callee hangs up after answer", status: call_status_enum.ACCEPTED, sender: callee, signal: callSignalHangup, want: call_status_enum.ENDED, wantOk: true},
+		{name: "start is not a transition", status: call_status_enum.RINGING, sender: caller, signal: callSignalStart, want: 0, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call := &model.CallSession{CallerId: caller, CalleeId: callee, Status: tt.status}
+			got, ok := signalTarget(call, tt.sender, tt.signal)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("signalTarget() = (%d, %v), want (%d, %v)", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
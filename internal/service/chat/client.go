@@ -9,11 +9,15 @@ import (
 	"gochat/internal/model"
 	myKafka "gochat/internal/service/kafka"
 	"gochat/pkg/constants"
+	"gochat/pkg/enum/message/frame_error_enum"
 	"gochat/pkg/enum/message/message_status_enum"
+	"gochat/pkg/util/random"
 	"gochat/pkg/zlog"
 	"log"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -30,10 +34,20 @@ type MessageBack struct {
 type Client struct {
 	Conn     *websocket.Conn   // WebSocket连接对象
 	Uuid     string            // 客户端唯一标识
-	SendTo   chan []byte       // 发送消息到服务器的通道
-	SendBack chan *MessageBack // 服务器回传消息到客户端的通道
+	ConnId   string            // 连接唯一标识，区分同一用户先后建立的连接
+	SendBack chan *MessageBack // 服务器回传消息到客户端的通道，即客户端的有界下行队列
+	// closeMutex 保护下行队列的关闭，读goroutine回送提示时据此判断队列是否已关闭
+	closeMutex sync.Mutex
+	closed     bool
+	// closeFrame 和 closeCode 连接关闭时发送给客户端的提示帧和关闭码，在关闭下行队列之前设置
+	// 提示帧不经过下行队列，队列已满时也能送达
+	closeFrame []byte
+	closeCode  int
 }
 
+// writers 所有运行中的写goroutine，关闭服务器时等待它们把剩余消息和关闭帧发送完毕
+var writers sync.WaitGroup
+
 // upgrader 用于将HTTP连接升级为WebSocket连接
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  2048, // 读取缓冲区大小
@@ -54,6 +68,8 @@ var messageMode = config.GetConfig().KafkaConfig.MessageMode
 // 每个客户端连接会启动一个goroutine执行此方法
 func (c *Client) Read() {
 	zlog.Info("ws read goroutine start")
+	// 无论因何退出读循环都要注销客户端，否则在线状态会一直保留到节点重启
+	defer c.unregister()
 	for {
 		// 阻塞读取WebSocket消息
 		_, jsonMessage, err := c.Conn.ReadMessage() // 阻塞状态
@@ -66,47 +82,68 @@ func (c *Client) Read() {
 		var message = request.ChatMessageRequest{}
 		if err := json.Unmarshal(jsonMessage, &message); err != nil {
 			zlog.Error(err.Error())
+			c.sendError(frame_error_enum.INVALID_MESSAGE, "消息格式错误")
+			continue
 		}
 		log.Println("接受到消息为: ", jsonMessage)
 
+		// 校验消息并以连接的用户身份覆盖发送者信息，未通过校验的消息直接回送错误帧，不进入转发流程
+		if rejection := prepareChatMessage(c.Uuid, &message); rejection != nil {
+			c.sendFeedback(newRejectFrame(rejection.code, rejection.message, message.ClientMessageId))
+			continue
+		}
+		if jsonMessage, err = json.Marshal(message); err != nil {
+			zlog.Error(err.Error())
+			c.sendError(frame_error_enum.SEND_FAILED, "消息发送失败，请稍后重试")
+			continue
+		}
+
 		// 根据配置的消息模式处理消息
 		if messageMode == "channel" {
 			// 通道模式：使用Go的channel进行消息传递
-			// 如果服务器的转发通道没满，先处理客户端缓存的消息
-			for len(ChatServer.Transmit) < constants.CHANNEL_SIZE && len(c.SendTo) > 0 {
-				sendToMessage := <-c.SendTo
-				ChatServer.SendMessageToTransmit(sendToMessage)
-			}
-
-			// 如果服务器通道没满且客户端缓存为空，直接发送到服务器通道
-			if len(ChatServer.Transmit) < constants.CHANNEL_SIZE {
-				ChatServer.SendMessageToTransmit(jsonMessage)
-			} else if len(c.SendTo) < constants.CHANNEL_SIZE {
-				// 如果服务器通道满了，将消息缓存到客户端通道
-				c.SendTo <- jsonMessage
-			} else {
-				// 通道都满了，返回错误提示
-				if err := c.Conn.WriteMessage(websocket.TextMessage, []byte("由于目前同一时间过多用户发送消息，消息发送失败，请稍后重试")); err != nil {
-					zlog.Error(err.Error())
-				}
+			// 转发通道已满时最多等待submitTimeout，超时说明服务器过载，向发送者回送错误帧
+			if IsShuttingDown() {
+				c.sendError(frame_error_enum.GOING_AWAY, "服务器正在关闭，消息发送失败，请重新连接后重试")
+			} else if !ChatServer.SendMessageToTransmit(jsonMessage, config.GetConfig().ChatConfig.SubmitTimeout*time.Millisecond) {
+				c.sendError(frame_error_enum.SERVER_BUSY, "服务器繁忙，消息发送失败，请稍后重试")
 			}
 		} else {
 			// Kafka模式：使用Kafka进行消息传递
+			// 写入器按配置的确认级别和退避策略重试，重试耗尽后才会返回错误
 			if err := myKafka.KafkaService.ChatWriter.WriteMessages(ctx, kafka.Message{
 				Key:   []byte(strconv.Itoa(config.GetConfig().KafkaConfig.Partition)),
 				Value: jsonMessage,
 			}); err != nil {
 				zlog.Error(err.Error())
+				// 投递失败需要告知发送者，否则前端会以为消息已经发出
+				c.sendError(frame_error_enum.SEND_FAILED, "消息发送失败，请稍后重试")
+				continue
 			}
 			zlog.Info("已发送消息：" + string(jsonMessage))
 		}
 	}
 }
 
+// unregister 读循环退出后从所在服务器的注册表中注销客户端，并标记下线或广播下线事件
+// 主动登出、被强制下线等由服务器关闭的连接已经注销，不会重复处理
+func (c *Client) unregister() {
+	if messageMode == "channel" {
+		ChatServer.Clients.Disconnect(c)
+	} else {
+		KafkaChatServer.Clients.Disconnect(c)
+	}
+}
+
 // Write 从服务器读取消息并发送到WebSocket连接
 // 每个客户端连接会启动一个goroutine执行此方法
 func (c *Client) Write() {
 	zlog.Info("ws write goroutine start")
+	// 先补发离线队列中的消息，保证离线消息先于新消息到达
+	if err := c.flushOffline(); err != nil {
+		zlog.Error(err.Error())
+		_ = c.Conn.Close()
+		return
+	}
 	// 阻塞从SendBack通道读取消息
 	for messageBack := range c.SendBack { // 阻塞状态
 		// 通过WebSocket发送消息给客户端
@@ -117,11 +154,72 @@ func (c *Client) Write() {
 		}
 		// log.Println("已发送消息：", messageBack.Message)
 
+		// 服务器提示类消息没有对应的消息记录，无需更新状态
+		if messageBack.Uuid == "" {
+			continue
+		}
+
 		// 消息发送成功，更新消息状态为已发送
 		if res := dao.GormDB.Model(&model.Message{}).Where("uuid = ?", messageBack.Uuid).Update("status", message_status_enum.Sent); res.Error != nil {
 			zlog.Error(res.Error.Error())
 		}
 	}
+	// 回传通道被关闭说明客户端已被移除，剩余消息发送完毕后发送关闭提示和关闭帧并关闭连接，读goroutine随之退出
+	if c.closeFrame != nil {
+		if err := c.Conn.WriteMessage(websocket.TextMessage, c.closeFrame); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+	closeCode := c.closeCode
+	if closeCode == 0 {
+		closeCode = websocket.CloseNormalClosure
+	}
+	_ = c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, ""), time.Now().Add(time.Second))
+	_ = c.Conn.Close()
+}
+
+// sendError 向发送者回送错误帧
+func (c *Client) sendError(code string, message string) {
+	c.sendFeedback(newErrorFrame(code, message))
+}
+
+// sendFeedback 通过写goroutine向客户端回送服务器提示
+// WebSocket连接不支持并发写，所以不能在读goroutine中直接写连接
+// 回传通道已满时丢弃提示，避免阻塞读取；客户端已被关闭时读goroutine可能仍在运行，此时提示直接丢弃
+func (c *Client) sendFeedback(feedback []byte) {
+	c.closeMutex.Lock()
+	defer c.closeMutex.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.SendBack <- &MessageBack{Message: feedback}:
+	default:
+		zlog.Warn("回传通道已满，丢弃服务器提示：" + string(feedback))
+	}
+}
+
+// forceClose 强制关闭客户端
+// 记录提示帧后关闭回传通道，写goroutine发送完剩余消息后送出提示帧并关闭连接
+// 调用方需持有注册表分片的写锁并已将客户端从分片中移除，保证关闭后不会再有消息投递到回传通道
+func (c *Client) forceClose(frame []byte, closeCode int) {
+	c.closeMutex.Lock()
+	defer c.closeMutex.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.closeFrame = frame
+	c.closeCode = closeCode
+	close(c.SendBack)
+}
+
+// sendQueueSize 客户端下行队列长度，未配置时使用默认通道大小
+func sendQueueSize() int {
+	if size := config.GetConfig().ChatConfig.SendQueueSize; size > 0 {
+		return size
+	}
+	return constants.CHANNEL_SIZE
 }
 
 // NewClientInit 初始化新的客户端连接
@@ -129,18 +227,28 @@ func (c *Client) Write() {
 func NewClientInit(c *gin.Context, clientId string) {
 	kafkaConfig := config.GetConfig().KafkaConfig
 
+	// 服务器关闭过程中不再接受新连接，客户端应连接其他节点或稍后重连
+	if IsShuttingDown() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    503,
+			"message": "服务器正在关闭，请稍后重连",
+		})
+		return
+	}
+
 	// 将HTTP连接升级为WebSocket连接
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		zlog.Error(err.Error())
+		return
 	}
 
 	// 创建新的Client对象
 	client := &Client{
-		Conn:     conn,                                            // WebSocket连接
-		Uuid:     clientId,                                        // 客户端唯一标识
-		SendTo:   make(chan []byte, constants.CHANNEL_SIZE),       // 发送消息到服务器的通道
-		SendBack: make(chan *MessageBack, constants.CHANNEL_SIZE), // 服务器回传消息的通道
+		Conn:     conn,                                      // WebSocket连接
+		Uuid:     clientId,                                  // 客户端唯一标识
+		ConnId:   "C" + random.GetNowAndLenRandomString(11), // 连接唯一标识，以'C'开头
+		SendBack: make(chan *MessageBack, sendQueueSize()),  // 服务器回传消息的通道
 	}
 
 	// 根据消息模式将客户端添加到对应的服务器
@@ -151,8 +259,12 @@ func NewClientInit(c *gin.Context, clientId string) {
 	}
 
 	// 启动客户端的读写goroutine
-	go client.Read()  // 读取客户端消息
-	go client.Write() // 向客户端写入消息
+	go client.Read() // 读取客户端消息
+	writers.Add(1)
+	go func() { // 向客户端写入消息
+		defer writers.Done()
+		client.Write()
+	}()
 
 	zlog.Info("ws连接成功")
 }
@@ -162,25 +274,16 @@ func NewClientInit(c *gin.Context, clientId string) {
 func ClientLogout(clientId string) (string, int) {
 	kafkaConfig := config.GetConfig().KafkaConfig
 
-	// 获取客户端对象
-	client := ChatServer.Clients[clientId]
-	if client != nil {
-		// 根据消息模式从对应的服务器中移除客户端
-		if kafkaConfig.MessageMode == "channel" {
+	// 获取客户端对象，不同消息模式下客户端注册在不同的服务器中
+	// 由服务器注销客户端并关闭下行队列，写goroutine送出登出确认后关闭连接
+	if kafkaConfig.MessageMode == "channel" {
+		if client, ok := ChatServer.Clients.Get(clientId); ok {
 			ChatServer.SendClientToLogout(client)
-		} else {
-			KafkaChatServer.SendClientToLogout(client)
 		}
-
-		// 关闭WebSocket连接
-		if err := client.Conn.Close(); err != nil {
-			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
+	} else {
+		if client, ok := KafkaChatServer.Clients.Get(clientId); ok {
+			KafkaChatServer.SendClientToLogout(client)
 		}
-
-		// 关闭客户端的通道
-		close(client.SendTo)
-		close(client.SendBack)
 	}
 
 	return "退出成功", 0
@@ -0,0 +1,90 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"gochat/internal/config"
+	"gochat/internal/dao"
+	"gochat/internal/dto/respond"
+	"gochat/internal/model"
+	myredis "gochat/internal/service/redis"
+	"gochat/pkg/zlog"
+	"time"
+)
+
+// maxClientMessageIdLength 客户端消息ID的最大长度，与数据库字段长度一致
+const maxClientMessageIdLength = 64
+
+// dedupKey 客户端消息ID去重记录的Redis键，值为对应的服务端消息ID
+func dedupKey(sendId string, clientMessageId string) string {
+	return "client_message_" + sendId + "_" + clientMessageId
+}
+
+// saveMessage 保存消息，并按(发送者, 客户端消息ID)去重
+// 先在Redis中登记客户端消息ID，登记失败说明是重复提交；Redis记录过期或Redis不可用时由数据库唯一索引兜底
+// 同一会话的消息由同一个worker顺序处理，重试的消息不会与第一次提交并发保存
+// 返回true表示是重复提交，消息没有再次保存，调用方不应再投递
+func saveMessage(clients *ClientRegistry, message *model.Message) (bool, error) {
+	if uuid, ok := claimClientMessageId(message); !ok {
+		sendAck(clients, message, uuid, true)
+		return true, nil
+	}
+	if res := dao.GormDB.Create(message); res.Error != nil {
+		// 唯一索引冲突说明消息已经保存过
+		if message.ClientMessageId.Valid {
+			var existing model.Message
+			if dao.GormDB.Select("uuid").Where("send_id = ? AND client_message_id = ?", message.SendId, message.ClientMessageId.String).First(&existing).Error == nil {
+				sendAck(clients, message, existing.Uuid, true)
+				return true, nil
+			}
+			// 保存失败，撤销登记，允许客户端重试
+			if err := myredis.DelKeyIfExists(dedupKey(message.SendId, message.ClientMessageId.String)); err != nil {
+				zlog.Error(err.Error())
+			}
+		}
+		return false, errors.New("消息保存失败：" + res.Error.Error())
+	}
+	sendAck(clients, message, message.Uuid, false)
+	return false, nil
+}
+
+// claimClientMessageId 在Redis中登记客户端消息ID
+// 返回false表示已被登记过，同时返回之前登记的服务端消息ID
+func claimClientMessageId(message *model.Message) (string, bool) {
+	if !message.ClientMessageId.Valid {
+		return "", true
+	}
+	key := dedupKey(message.SendId, message.ClientMessageId.String)
+	ok, err := myredis.SetKeyNX(key, message.Uuid, config.GetConfig().ChatConfig.DedupExpire*time.Hour)
+	if err != nil {
+		zlog.Error(err.Error())
+		return "", true // Redis不可用时交给数据库唯一索引判断
+	}
+	if ok {
+		return "", true
+	}
+	uuid, err := myredis.GetKey(key)
+	if err != nil || uuid == "" {
+		return "", true // 记录恰好过期，交给数据库唯一索引判断
+	}
+	return uuid, false
+}
+
+// sendAck 向发送者回送客户端消息ID与服务端消息ID的对应关系
+// 未携带客户端消息ID的消息不回送
+func sendAck(clients *ClientRegistry, message *model.Message, uuid string, duplicate bool) {
+	if !message.ClientMessageId.Valid {
+		return
+	}
+	ack, err := json.Marshal(respond.ChatAckRespond{
+		Frame:           "ack",
+		ClientMessageId: message.ClientMessageId.String,
+		Uuid:            uuid,
+		Duplicate:       duplicate,
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	clients.Send(message.SendId, &MessageBack{Message: ack})
+}
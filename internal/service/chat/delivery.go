@@ -0,0 +1,131 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"gochat/internal/config"
+	"gochat/internal/dao"
+	"gochat/internal/dto/respond"
+	"gochat/internal/model"
+	myredis "gochat/internal/service/redis"
+	"gochat/pkg/enum/message/frame_error_enum"
+	"gochat/pkg/enum/message/message_status_enum"
+	"gochat/pkg/zlog"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 慢消费者策略，客户端下行队列已满时的处理方式
+const (
+	slowConsumerDrop       = "drop"       // 丢弃本条消息，消息仍保存在数据库中，可通过历史记录拉取
+	slowConsumerDisconnect = "disconnect" // 断开客户端连接，由客户端重连后拉取历史记录
+	slowConsumerOffline    = "offline"    // 将消息暂存到离线队列并断开连接，重连后先补发离线消息
+)
+
+// offlineFlushBatch 每次从离线队列取出的消息条数
+const offlineFlushBatch = 100
+
+// offlineMessage 暂存在Redis离线队列中的消息
+type offlineMessage struct {
+	Message []byte `json:"message"`
+	Uuid    string `json:"uuid"`
+}
+
+// offlineKey 用户离线队列的Redis键
+func offlineKey(uuid string) string {
+	return "offline_message_" + uuid
+}
+
+// deliver 以非阻塞方式将消息放入客户端的下行队列
+// 队列已满时按配置的慢消费者策略处理，返回true表示调用方需要断开该客户端
+// 调用方需持有客户端所在注册表分片的读锁，保证投递期间下行队列不会被关闭
+func deliver(client *Client, messageBack *MessageBack) bool {
+	select {
+	case client.SendBack <- messageBack:
+		return false
+	default:
+	}
+
+	switch config.GetConfig().ChatConfig.SlowConsumerPolicy {
+	case slowConsumerDisconnect:
+		zlog.Warn(fmt.Sprintf("用户%s下行队列已满，断开连接", client.Uuid))
+		return true
+	case slowConsumerOffline:
+		zlog.Warn(fmt.Sprintf("用户%s下行队列已满，消息转存离线队列并断开连接", client.Uuid))
+		spillOffline(client.Uuid, messageBack)
+		return true
+	default:
+		zlog.Warn(fmt.Sprintf("用户%s下行队列已满，丢弃消息%s", client.Uuid, messageBack.Uuid))
+		return false
+	}
+}
+
+// spillOffline 将消息写入用户的离线队列，队列超过上限时丢弃最早的消息
+func spillOffline(uuid string, messageBack *MessageBack) {
+	chatConfig := config.GetConfig().ChatConfig
+	value, err := json.Marshal(offlineMessage{Message: messageBack.Message, Uuid: messageBack.Uuid})
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if err := myredis.PushListWithLimit(offlineKey(uuid), string(value), chatConfig.OfflineQueueLimit, chatConfig.OfflineExpire*time.Hour); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// flushOffline 将离线队列中的消息直接写入WebSocket连接
+// 只能在写goroutine中调用，保证连接没有并发写；离线消息早于新消息，需在处理下行队列之前发送
+func (c *Client) flushOffline() error {
+	for {
+		values, err := myredis.PopListBatch(offlineKey(c.Uuid), offlineFlushBatch)
+		if err != nil {
+			zlog.Error(err.Error())
+			return nil // 离线队列不可用时不影响正常收发
+		}
+		for _, value := range values {
+			var message offlineMessage
+			if err := json.Unmarshal([]byte(value), &message); err != nil {
+				zlog.Error(err.Error())
+				continue
+			}
+			if err := c.Conn.WriteMessage(websocket.TextMessage, message.Message); err != nil {
+				return err
+			}
+			if message.Uuid == "" {
+				continue
+			}
+			if res := dao.GormDB.Model(&model.Message{}).Where("uuid = ?", message.Uuid).Update("status", message_status_enum.Sent); res.Error != nil {
+				zlog.Error(res.Error.Error())
+			}
+		}
+		if len(values) < offlineFlushBatch {
+			return nil
+		}
+	}
+}
+
+// newErrorFrame 构建回送给发送者的错误帧
+func newErrorFrame(code string, message string) []byte {
+	return newRejectFrame(code, message, "")
+}
+
+// newRejectFrame 构建消息被拒绝时回送给发送者的错误帧，携带被拒绝消息的客户端消息ID
+func newRejectFrame(code string, message string, clientMessageId string) []byte {
+	frame, err := json.Marshal(respond.ChatErrorRespond{
+		Frame:           "error",
+		Code:            code,
+		Message:         message,
+		ClientMessageId: clientMessageId,
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+	}
+	return frame
+}
+
+// slowConsumerFrame 因接收过慢被断开时回送给客户端的错误帧
+var slowConsumerFrame = newErrorFrame(frame_error_enum.SLOW_CONSUMER, "消息接收过慢，连接已断开，请重新连接")
+
+// replacedFrame 同一用户在其他地方重新连接时回送给旧连接的错误帧
+var replacedFrame = newErrorFrame(frame_error_enum.REPLACED, "账号已在其他地方登录，当前连接已断开")
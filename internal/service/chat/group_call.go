@@ -0,0 +1,359 @@
+package chat
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gochat/internal/config"
+	"gochat/internal/dao"
+	"gochat/internal/dto/request"
+	"gochat/internal/dto/respond"
+	"gochat/internal/model"
+	"gochat/pkg/enum/call/group_call_status_enum"
+	"gochat/pkg/enum/message/frame_error_enum"
+	"gochat/pkg/enum/message/message_status_enum"
+	"gochat/pkg/enum/message/message_type_enum"
+	"gochat/pkg/util/random"
+	"gochat/pkg/zlog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 群通话的房间信令，start_call在群内没有进行中的通话时创建房间，否则等同于加入；end_call等同于离开
+// SDP、ICE等其余信令按targetId在两个参与者之间转发
+const (
+	groupCallSignalJoin  = "join_call"  // 加入群通话
+	groupCallSignalLeave = "leave_call" // 离开群通话
+)
+
+// 群通话帧的事件类型
+const (
+	groupCallEventStart = "start"
+	groupCallEventJoin  = "join"
+	groupCallEventLeave = "leave"
+	groupCallEventEnd   = "end"
+)
+
+// handleGroupAVMessage 处理群通话信令
+// 同一群聊的信令由同一个worker顺序处理，房间的加入和离开不会并发执行
+func handleGroupAVMessage(clients *ClientRegistry, message *model.Message, avData *request.AVData) error {
+	switch avData.Type {
+	case callSignalStart, groupCallSignalJoin:
+		return joinGroupCall(clients, message, avData)
+	case groupCallSignalLeave, callSignalHangup:
+		return leaveGroupCall(clients, message, avData)
+	default:
+		return relayGroupCallSignal(clients, message, avData)
+	}
+}
+
+// loadActiveGroupCall 查询群聊中进行中的群通话，callId不为空时还要求是该通话
+// 没有进行中的群通话时返回nil
+func loadActiveGroupCall(groupId string, callId string) (*model.GroupCall, error) {
+	var room model.GroupCall
+	if res := dao.GormDB.Where("group_id = ? AND status = ?", groupId, group_call_status_enum.ACTIVE).First(&room); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, errors.New("群通话查询失败：" + res.Error.Error())
+	}
+	if callId != "" && callId != room.Uuid {
+		return nil, nil
+	}
+	return &room, nil
+}
+
+// groupCallParticipants 查询群通话当前的参与者
+func groupCallParticipants(callId string) ([]string, error) {
+	var participants []string
+	if res := dao.GormDB.Model(&model.GroupCallMember{}).Where("call_id = ? AND left_at IS NULL", callId).
+		Order("joined_at ASC").Pluck("user_id", &participants); res.Error != nil {
+		return nil, errors.New("群通话参与者查询失败：" + res.Error.Error())
+	}
+	return participants, nil
+}
+
+// groupMemberIds 解析群成员列表
+func groupMemberIds(groupId string) ([]string, error) {
+	var group model.GroupInfo
+	if res := dao.GormDB.Select("uuid", "members").First(&group, "uuid = ?", groupId); res.Error != nil {
+		return nil, errors.New("群聊查询失败：" + res.Error.Error())
+	}
+	var members []string
+	if err := json.Unmarshal(group.Members, &members); err != nil {
+		return nil, errors.New("群成员解析失败：" + err.Error())
+	}
+	return members, nil
+}
+
+// joinGroupCall 加入群通话，start_call在群内没有进行中的通话时创建房间并通知全部群成员
+// 新加入者从状态帧中获得其他参与者，由新加入者向每个参与者发起连接
+func joinGroupCall(clients *ClientRegistry, message *model.Message, avData *request.AVData) error {
+	room, err := loadActiveGroupCall(message.ReceiveId, avData.CallId)
+	if err != nil {
+		return err
+	}
+	if room == nil && (avData.Type != callSignalStart || avData.CallId != "") {
+		sendReject(clients, message, reject(frame_error_enum.CALL_INVALID, "群通话不存在或已结束"))
+		return nil
+	}
+
+	var participants []string
+	created := room == nil
+	if created {
+		room = &model.GroupCall{
+			Uuid:      fmt.Sprintf("R%s", random.GetNowAndLenRandomString(11)), // 生成唯一群通话ID，以'R'开头
+			GroupId:   message.ReceiveId,
+			StarterId: message.SendId,
+			Status:    group_call_status_enum.ACTIVE,
+			CreatedAt: time.Now(),
+		}
+	} else {
+		if participants, err = groupCallParticipants(room.Uuid); err != nil {
+			return err
+		}
+		for _, participant := range participants {
+			if participant == message.SendId {
+				// 重复加入时只把当前状态发给自己，用于客户端重连后恢复
+				pushGroupCallFrame([]string{message.SendId}, room, groupCallEventJoin, message.SendId, participants)
+				return nil
+			}
+		}
+		if maxSize := config.GetConfig().ChatConfig.MaxGroupCallSize; maxSize > 0 && len(participants) >= maxSize {
+			sendReject(clients, message, reject(frame_error_enum.CALL_FULL, fmt.Sprintf("群通话最多%d人参与", maxSize)))
+			return nil
+		}
+	}
+
+	claimed, err := claimCallLine(message.SendId, room.Uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+		sendReject(clients, message, reject(frame_error_enum.SEND_FAILED, "加入群通话失败，请稍后重试"))
+		return nil
+	}
+	if !claimed {
+		sendReject(clients, message, reject(frame_error_enum.CALL_BUSY, "你正在通话中，无法加入群通话"))
+		return nil
+	}
+
+	if err := dao.GormDB.Transaction(func(tx *gorm.DB) error {
+		if created {
+			if res := tx.Create(room); res.Error != nil {
+				return res.Error
+			}
+		}
+		return tx.Create(&model.GroupCallMember{CallId: room.Uuid, UserId: message.SendId, JoinedAt: time.Now()}).Error
+	}); err != nil {
+		releaseCallLine(message.SendId, room.Uuid)
+		return errors.New("加入群通话失败：" + err.Error())
+	}
+	participants = append(participants, message.SendId)
+
+	if !created {
+		pushGroupCallFrame(participants, room, groupCallEventJoin, message.SendId, participants)
+		return nil
+	}
+	members, err := groupMemberIds(room.GroupId)
+	if err != nil {
+		zlog.Error(err.Error())
+		members = participants
+	}
+	pushGroupCallFrame(members, room, groupCallEventStart, message.SendId, participants)
+	return nil
+}
+
+// leaveGroupCall 离开群通话，最后一个参与者离开时结束房间
+func leaveGroupCall(clients *ClientRegistry, message *model.Message, avData *request.AVData) error {
+	room, err := loadActiveGroupCall(message.ReceiveId, avData.CallId)
+	if err != nil {
+		return err
+	}
+	if room == nil {
+		sendReject(clients, message, reject(frame_error_enum.CALL_INVALID, "群通话不存在或已结束"))
+		return nil
+	}
+	left, err := removeGroupCallParticipant(room, message.SendId)
+	if err != nil {
+		return err
+	}
+	if !left {
+		sendReject(clients, message, reject(frame_error_enum.CALL_INVALID, "你不在该群通话中"))
+	}
+	return nil
+}
+
+// removeGroupCallParticipant 让用户离开群通话并释放其线路，最后一个参与者离开时结束房间
+// 用户不在房间内时返回false
+func removeGroupCallParticipant(room *model.GroupCall, userId string) (bool, error) {
+	res := dao.GormDB.Model(&model.GroupCallMember{}).Where("call_id = ? AND user_id = ? AND left_at IS NULL", room.Uuid, userId).
+		Update("left_at", time.Now())
+	if res.Error != nil {
+		return false, errors.New("离开群通话失败：" + res.Error.Error())
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	releaseCallLine(userId, room.Uuid)
+
+	participants, err := groupCallParticipants(room.Uuid)
+	if err != nil {
+		return true, err
+	}
+	if len(participants) > 0 {
+		// 离开者也需要收到帧，用于确认已离开
+		pushGroupCallFrame(append(participants, userId), room, groupCallEventLeave, userId, participants)
+		return true, nil
+	}
+	return true, endGroupCall(room)
+}
+
+// endGroupCall 结束群通话，让仍在房间内的参与者离开，通知全部群成员并写入通话记录
+// 以条件更新的方式结束，房间已结束时不做处理
+func endGroupCall(room *model.GroupCall) error {
+	now := time.Now()
+	duration := int(now.Sub(room.CreatedAt).Milliseconds())
+	res := dao.GormDB.Model(&model.GroupCall{}).Where("uuid = ? AND status = ?", room.Uuid, group_call_status_enum.ACTIVE).
+		Updates(map[string]interface{}{
+			"status":   group_call_status_enum.ENDED,
+			"ended_at": now,
+			"duration": duration,
+		})
+	if res.Error != nil {
+		return errors.New("结束群通话失败：" + res.Error.Error())
+	}
+	if res.RowsAffected == 0 {
+		return nil
+	}
+	room.Status = group_call_status_enum.ENDED
+	room.EndedAt = sql.NullTime{Time: now, Valid: true}
+	room.Duration = duration
+
+	// 超时结束时房间内可能还有参与者
+	participants, err := groupCallParticipants(room.Uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+	}
+	if len(participants) > 0 {
+		if res := dao.GormDB.Model(&model.GroupCallMember{}).Where("call_id = ? AND left_at IS NULL", room.Uuid).
+			Update("left_at", now); res.Error != nil {
+			zlog.Error(res.Error.Error())
+		}
+		for _, participant := range participants {
+			releaseCallLine(participant, room.Uuid)
+		}
+	}
+
+	members, err := groupMemberIds(room.GroupId)
+	if err != nil {
+		return err
+	}
+	pushGroupCallFrame(members, room, groupCallEventEnd, "", nil)
+	return saveGroupCallLog(room, members)
+}
+
+// relayGroupCallSignal 在群通话的两个参与者之间转发SDP、ICE等信令
+func relayGroupCallSignal(clients *ClientRegistry, message *model.Message, avData *request.AVData) error {
+	room, err := loadActiveGroupCall(message.ReceiveId, avData.CallId)
+	if err != nil {
+		return err
+	}
+	if room == nil {
+		sendReject(clients, message, reject(frame_error_enum.CALL_INVALID, "群通话不存在或已结束"))
+		return nil
+	}
+	participants, err := groupCallParticipants(room.Uuid)
+	if err != nil {
+		return err
+	}
+	var senderIn, targetIn bool
+	for _, participant := range participants {
+		senderIn = senderIn || participant == message.SendId
+		targetIn = targetIn || participant == avData.TargetId
+	}
+	if !senderIn || !targetIn || avData.TargetId == message.SendId {
+		sendReject(clients, message, reject(frame_error_enum.CALL_INVALID, "信令的发送者和接收者必须是群通话的参与者"))
+		return nil
+	}
+	avData.CallId = room.Uuid
+	forwardAVMessage(clients, message, avData, avData.TargetId)
+	return nil
+}
+
+// pushGroupCallFrame 向一组用户下发群通话帧
+func pushGroupCallFrame(userIds []string, room *model.GroupCall, event string, userId string, participants []string) {
+	if participants == nil {
+		participants = []string{}
+	}
+	frame, err := json.Marshal(respond.GroupCallFrameRespond{
+		Frame:        "group_call",
+		Event:        event,
+		CallId:       room.Uuid,
+		GroupId:      room.GroupId,
+		StarterId:    room.StarterId,
+		UserId:       userId,
+		Status:       room.Status,
+		Participants: participants,
+		Duration:     room.Duration,
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	for _, id := range userIds {
+		pushFrame(id, frame)
+	}
+}
+
+// saveGroupCallLog 以发起者为发送者写入一条群通话记录消息，并推送给全部群成员
+func saveGroupCallLog(room *model.GroupCall, members []string) error {
+	var starter model.UserInfo
+	if res := dao.GormDB.Select("uuid", "nickname", "avatar").First(&starter, "uuid = ?", room.StarterId); res.Error != nil {
+		return errors.New("群通话发起者查询失败：" + res.Error.Error())
+	}
+	avData, err := json.Marshal(callLog{CallId: room.Uuid, Type: "call_log", Status: room.Status, Duration: room.Duration})
+	if err != nil {
+		return err
+	}
+	message := model.Message{
+		Uuid:       fmt.Sprintf("M%s", random.GetNowAndLenRandomString(11)),
+		Type:       message_type_enum.AudioOrVideo,
+		Content:    "群通话已结束，时长 " + formatCallDuration(room.Duration),
+		SendId:     starter.Uuid,
+		SendName:   starter.Nickname,
+		SendAvatar: starter.Avatar,
+		ReceiveId:  room.GroupId,
+		FileSize:   "0B",
+		Status:     message_status_enum.Sent, // 通话记录由服务端生成，不跟踪投递状态
+		CreatedAt:  time.Now(),
+		Duration:   room.Duration,
+		AVdata:     string(avData),
+	}
+	if res := dao.GormDB.Create(&message); res.Error != nil {
+		return errors.New("群通话记录保存失败：" + res.Error.Error())
+	}
+
+	messageRsp := respond.GetGroupMessageListRespond{
+		SendId:     message.SendId,
+		SendName:   message.SendName,
+		SendAvatar: message.SendAvatar,
+		ReceiveId:  message.ReceiveId,
+		Type:       message.Type,
+		Content:    message.Content,
+		FileSize:   message.FileSize,
+		CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+
+		Duration: message.Duration,
+		AVdata:   message.AVdata,
+	}
+	jsonMessage, err := json.Marshal(messageRsp)
+	if err != nil {
+		return err
+	}
+	for _, memberId := range members {
+		pushFrame(memberId, jsonMessage)
+	}
+	appendToListCache("group_messagelist_"+room.GroupId, messageRsp)
+	return nil
+}
@@ -0,0 +1,66 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"gochat/internal/dao"
+	"gochat/internal/model"
+	"gochat/pkg/enum/contact/contact_status_enum"
+	"gochat/pkg/enum/message/frame_error_enum"
+	"gochat/pkg/zlog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// checkGroupSpeak 校验发送者当前能否在群内发言
+// 群主和管理员不受禁言限制；全员禁言时只有群主和管理员可以发言；
+// 成员禁言到期后即可发言，禁言状态由定时任务恢复并通知成员
+func checkGroupSpeak(group *model.GroupInfo, sendId string) *messageRejection {
+	member, rejection := loadGroupMember(group.Uuid, sendId)
+	if rejection != nil {
+		return rejection
+	}
+	if group.IsManager(sendId) {
+		return nil
+	}
+	if group.MuteAll == 1 {
+		return reject(frame_error_enum.MUTED, "群聊已开启全员禁言，仅群主和管理员可以发言")
+	}
+	if member.Status != contact_status_enum.SILENCE {
+		return nil
+	}
+	if !member.MuteUntil.Valid {
+		return reject(frame_error_enum.MUTED, "你已被禁言")
+	}
+	if time.Now().Before(member.MuteUntil.Time) {
+		return reject(frame_error_enum.MUTED, fmt.Sprintf("你已被禁言，%s后解除", member.MuteUntil.Time.Format("2006-01-02 15:04:05")))
+	}
+	return nil
+}
+
+// loadGroupMember 查询用户与群聊的成员关系，用户不是群成员时返回拒绝原因
+// 被禁言的成员仍是群成员，是否能发言由调用方判断
+func loadGroupMember(groupId string, userId string) (*model.UserContact, *messageRejection) {
+	var member model.UserContact
+	if res := dao.GormDB.Where("user_id = ? AND contact_id = ?", userId, groupId).First(&member); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return nil, reject(frame_error_enum.NOT_GROUP_MEMBER, "你不是该群成员")
+		}
+		zlog.Error(res.Error.Error())
+		return nil, reject(frame_error_enum.SEND_FAILED, "消息发送失败，请稍后重试")
+	}
+	if member.Status != contact_status_enum.NORMAL && member.Status != contact_status_enum.SILENCE {
+		return nil, reject(frame_error_enum.NOT_GROUP_MEMBER, "你不是该群成员")
+	}
+	return &member, nil
+}
+
+// sendReject 向发送者回送消息被拒绝的错误帧
+func sendReject(clients *ClientRegistry, message *model.Message, rejection *messageRejection) {
+	var clientMessageId string
+	if message.ClientMessageId.Valid {
+		clientMessageId = message.ClientMessageId.String
+	}
+	clients.Send(message.SendId, &MessageBack{Message: newRejectFrame(rejection.code, rejection.message, clientMessageId)})
+}
@@ -0,0 +1,60 @@
+package chat
+
+import (
+	"errors"
+	"gochat/internal/dao"
+	"gochat/internal/model"
+	"gochat/internal/service/storage"
+	"gochat/pkg/zlog"
+	"path"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// fillMediaMetadata 根据发送者的上传记录填写文件消息的图片尺寸、音频时长和缩略图
+// 客户端提交的只有文件地址，媒体信息以服务端解析的上传记录为准；找不到上传记录时保持为空
+func fillMediaMetadata(message *model.Message) {
+	file, err := findUploadedFile(message.Url, message.SendId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if file == nil {
+		return
+	}
+	message.Width = file.Width
+	message.Height = file.Height
+	message.Duration = file.Duration
+	message.ThumbnailUrl = file.ThumbnailUrl
+}
+
+// findUploadedFile 根据消息中的文件地址查找指定用户上传该聊天文件的记录
+// 不是服务端保存的聊天文件，或该用户没有上传过该文件时返回nil；同一文件被不同用户上传时各有一条记录
+func findUploadedFile(url string, uploaderId string) (*model.UploadedFile, error) {
+	key, ok := chatFileKey(url)
+	if !ok {
+		return nil, nil
+	}
+	// 保存的文件名由内容哈希和扩展名组成，按有索引的哈希查询
+	storageName := path.Base(key)
+	hash := strings.SplitN(storageName, ".", 2)[0]
+	var file model.UploadedFile
+	if res := dao.GormDB.Where("hash = ? AND storage_name = ? AND uploader_id = ?", hash, storageName, uploaderId).
+		Order("created_at desc").First(&file); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, res.Error
+	}
+	return &file, nil
+}
+
+// chatFileKey 解析消息中的文件地址，返回服务端保存的聊天文件的存储键，不是聊天文件时返回false
+func chatFileKey(url string) (string, bool) {
+	key, ok := storage.KeyFromURL(url)
+	if !ok || !strings.HasPrefix(key, "files/") {
+		return "", false
+	}
+	return key, true
+}
@@ -0,0 +1,298 @@
+package chat
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gochat/internal/dao"
+	"gochat/internal/dto/request"
+	"gochat/internal/dto/respond"
+	"gochat/internal/model"
+	myredis "gochat/internal/service/redis"
+	"gochat/internal/service/storage"
+	"gochat/pkg/constants"
+	"gochat/pkg/enum/message/message_status_enum"
+	"gochat/pkg/enum/message/message_type_enum"
+	"gochat/pkg/util/random"
+	"gochat/pkg/zlog"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// handleChatMessage 处理一条聊天消息，channel模式和kafka模式共用
+// 保存消息、投递给本节点上在线的接收者和发送者，并更新历史消息缓存
+// 返回的错误表示消息无法解析或保存，kafka模式下由调用方转存死信；
+// 消息保存之后的失败（如查询群成员失败）只记录日志，不再返回错误，避免重放产生重复消息
+func handleChatMessage(clients *ClientRegistry, chatMessageReq request.ChatMessageRequest) error {
+	// 接收者ID为空时无法判断私聊还是群聊
+	if chatMessageReq.ReceiveId == "" {
+		return errors.New("接收者ID为空")
+	}
+	if len(chatMessageReq.ClientMessageId) > maxClientMessageIdLength {
+		return errors.New("客户端消息ID过长")
+	}
+
+	switch chatMessageReq.Type {
+	case message_type_enum.Text, message_type_enum.Voice, message_type_enum.File:
+		return handleContentMessage(clients, chatMessageReq)
+	case message_type_enum.AudioOrVideo:
+		return handleAVMessage(clients, chatMessageReq)
+	default:
+		return fmt.Errorf("未知的消息类型：%d", chatMessageReq.Type)
+	}
+}
+
+// newMessage 根据请求构建待保存的消息模型
+func newMessage(chatMessageReq request.ChatMessageRequest) model.Message {
+	message := model.Message{
+		Uuid:       fmt.Sprintf("M%s", random.GetNowAndLenRandomString(11)), // 生成唯一消息ID
+		SessionId:  chatMessageReq.SessionId,                                // 会话ID
+		Type:       chatMessageReq.Type,                                     // 消息类型
+		SendId:     chatMessageReq.SendId,                                   // 发送者ID
+		SendName:   chatMessageReq.SendName,                                 // 发送者姓名
+		SendAvatar: normalizePath(chatMessageReq.SendAvatar),                // 发送者头像，去除/static之前的内容，防止ip前缀引入
+		ReceiveId:  chatMessageReq.ReceiveId,                                // 接收者ID
+		Status:     message_status_enum.Unsent,                              // 消息状态：未发送
+		CreatedAt:  time.Now(),                                              // 消息创建时间
+		ClientMessageId: sql.NullString{ // 客户端消息ID，未携带时存为NULL
+			String: chatMessageReq.ClientMessageId,
+			Valid:  chatMessageReq.ClientMessageId != "",
+		},
+	}
+	switch chatMessageReq.Type {
+	case message_type_enum.Text:
+		message.Content = chatMessageReq.Content
+		message.FileSize = "0B" // 文本消息无文件
+	case message_type_enum.Voice, message_type_enum.File:
+		message.Url = chatMessageReq.Url
+		message.FileSize = chatMessageReq.FileSize
+		message.FileType = chatMessageReq.FileType
+		message.FileName = chatMessageReq.FileName
+		fillMediaMetadata(&message)
+	case message_type_enum.AudioOrVideo:
+		message.AVdata = chatMessageReq.AVdata
+	}
+	return message
+}
+
+// handleContentMessage 处理文本、语音和文件消息，语音消息与文件消息的投递方式相同
+func handleContentMessage(clients *ClientRegistry, chatMessageReq request.ChatMessageRequest) error {
+	message := newMessage(chatMessageReq)
+
+	// 根据接收者ID首字母判断消息类型：'U'为用户私聊，'G'为群聊
+	switch message.ReceiveId[0] {
+	case 'U':
+		// 如果能找到ReceiveId，说明在线，可以发送，否则存表后跳过
+		// 因为在线的时候是通过websocket更新消息记录的，离线后通过存表，登录时只调用一次数据库操作
+		// 切换chat对象后，前端的messageList也会改变，获取messageList从第二次就是从redis中获取
+		messageRsp := respond.GetMessageListRespond{
+			SendId:     message.SendId,
+			SendName:   message.SendName,
+			SendAvatar: chatMessageReq.SendAvatar,
+			ReceiveId:  message.ReceiveId,
+			Type:       message.Type,
+			Content:    message.Content,
+			Url:        message.Url,
+			FileSize:   message.FileSize,
+			FileName:   message.FileName,
+			FileType:   message.FileType,
+			CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+
+			Width:        message.Width,
+			Height:       message.Height,
+			Duration:     message.Duration,
+			ThumbnailUrl: message.ThumbnailUrl,
+		}
+		// 缓存中保存缩略图的固定访问路径，推送给发送者和接收者的消息分别使用签名给各自的下载地址
+		pushRsp := messageRsp
+		pushRsp.ThumbnailUrl = storage.SignStaticURL(messageRsp.ThumbnailUrl, message.SendId)
+		jsonMessage, err := json.Marshal(pushRsp)
+		if err != nil {
+			return errors.New("消息序列化失败：" + err.Error())
+		}
+		receiverRsp := messageRsp
+		receiverRsp.ThumbnailUrl = storage.SignStaticURL(messageRsp.ThumbnailUrl, message.ReceiveId)
+		receiverMessage, err := json.Marshal(receiverRsp)
+		if err != nil {
+			return errors.New("消息序列化失败：" + err.Error())
+		}
+
+		// 1. 接收者拉黑了发送者时按拉黑策略处理，消息不保存
+		if isBlockedBy(message.ReceiveId, message.SendId) {
+			handleBlocked(clients, &message, jsonMessage)
+			return nil
+		}
+
+		// 2. 保存消息到数据库，重复提交的消息只回送确认，不再投递
+		if duplicate, err := saveMessage(clients, &message); err != nil || duplicate {
+			return err
+		}
+		messageBack := &MessageBack{Message: jsonMessage, Uuid: message.Uuid}
+
+		// 接收者对会话开启了免打扰时，推送带免打扰标记的消息，前端据此不弹出提醒
+		receiverBack := &MessageBack{Message: receiverMessage, Uuid: message.Uuid}
+		if _, muted := splitMuted(message.SendId, []string{message.ReceiveId}); len(muted) > 0 {
+			if mutedBack := newMutedMessageBack(receiverRsp, message.Uuid); mutedBack != nil {
+				receiverBack = mutedBack
+			}
+		}
+		clients.Send(message.ReceiveId, receiverBack)
+		// 因为send_id肯定在线，所以这里在后端进行在线回显message，其实优化的话前端可以直接回显
+		// 问题在于前后端的req和rsp结构不同，前端存储message的messageList不能存req，只能存rsp
+		// 所以这里后端进行回显，前端不回显
+		clients.Send(message.SendId, messageBack)
+
+		// 3. 更新Redis缓存中的私聊消息列表
+		appendToListCache("message_list_"+message.SendId+"_"+message.ReceiveId, messageRsp)
+
+	case 'G':
+		// 1. 查询群组信息，解析群组成员列表
+		var group model.GroupInfo
+		if res := dao.GormDB.Where("uuid = ?", message.ReceiveId).First(&group); res.Error != nil {
+			return errors.New("群聊查询失败：" + res.Error.Error())
+		}
+		var members []string
+		if err := json.Unmarshal(group.Members, &members); err != nil {
+			return errors.New("群成员解析失败：" + err.Error())
+		}
+
+		// 消息排队期间可能被禁言或开启了全员禁言，投递前再校验一次
+		if rejection := checkGroupSpeak(&group, message.SendId); rejection != nil {
+			sendReject(clients, &message, rejection)
+			return nil
+		}
+
+		// 2. 保存消息到数据库，重复提交的消息只回送确认，不再投递
+		if duplicate, err := saveMessage(clients, &message); err != nil || duplicate {
+			return err
+		}
+
+		messageRsp := respond.GetGroupMessageListRespond{
+			SendId:     message.SendId,
+			SendName:   message.SendName,
+			SendAvatar: chatMessageReq.SendAvatar,
+			ReceiveId:  message.ReceiveId,
+			Type:       message.Type,
+			Content:    message.Content,
+			Url:        message.Url,
+			FileSize:   message.FileSize,
+			FileName:   message.FileName,
+			FileType:   message.FileType,
+			CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+
+			Width:        message.Width,
+			Height:       message.Height,
+			Duration:     message.Duration,
+			ThumbnailUrl: message.ThumbnailUrl,
+		}
+		// 3. 拉黑了发送者的成员看不到该消息，成员中包含发送者，发送者也会收到消息回显
+		// 对群聊开启了免打扰的成员收到带免打扰标记的消息
+		normal, muted := splitMuted(message.ReceiveId, excludeBlockers(message.SendId, members))
+		broadcastGroupMessage(clients, messageRsp, message.Uuid, normal, muted)
+
+		// 4. 更新Redis缓存中的群组消息列表
+		appendToListCache("group_messagelist_"+message.ReceiveId, messageRsp)
+	}
+	return nil
+}
+
+// broadcastGroupMessage 向群成员推送群聊消息，muted为开启了免打扰的成员
+// 缓存中保存缩略图的固定访问路径，推送的消息中缩略图为每个成员单独签名的下载地址；
+// 没有缩略图时所有成员收到同一条消息，按分片批量投递
+func broadcastGroupMessage(clients *ClientRegistry, messageRsp respond.GetGroupMessageListRespond, uuid string, normal []string, muted []string) {
+	if messageRsp.ThumbnailUrl == "" {
+		jsonMessage, err := json.Marshal(messageRsp)
+		if err != nil {
+			zlog.Error(err.Error())
+			return
+		}
+		messageBack := &MessageBack{Message: jsonMessage, Uuid: uuid}
+		clients.Broadcast(normal, messageBack)
+		if len(muted) > 0 {
+			if mutedBack := newMutedMessageBack(messageRsp, uuid); mutedBack != nil {
+				clients.Broadcast(muted, mutedBack)
+			} else {
+				clients.Broadcast(muted, messageBack)
+			}
+		}
+		return
+	}
+
+	sendSigned := func(userId string, isMuted bool) {
+		rsp := messageRsp
+		rsp.ThumbnailUrl = storage.SignStaticURL(messageRsp.ThumbnailUrl, userId)
+		var messageBack *MessageBack
+		if isMuted {
+			messageBack = newMutedMessageBack(rsp, uuid)
+		}
+		if messageBack == nil {
+			jsonMessage, err := json.Marshal(rsp)
+			if err != nil {
+				zlog.Error(err.Error())
+				return
+			}
+			messageBack = &MessageBack{Message: jsonMessage, Uuid: uuid}
+		}
+		clients.Send(userId, messageBack)
+	}
+	for _, userId := range normal {
+		sendSigned(userId, false)
+	}
+	for _, userId := range muted {
+		sendSigned(userId, true)
+	}
+}
+
+// newMutedMessageBack 构建带免打扰标记的推送消息，messageRsp为私聊或群聊消息响应对象
+// 只修改副本，缓存中的历史消息不带免打扰标记
+func newMutedMessageBack(messageRsp interface{}, uuid string) *MessageBack {
+	switch rsp := messageRsp.(type) {
+	case respond.GetMessageListRespond:
+		rsp.Muted = true
+		messageRsp = rsp
+	case respond.GetGroupMessageListRespond:
+		rsp.Muted = true
+		messageRsp = rsp
+	}
+	jsonMessage, err := json.Marshal(messageRsp)
+	if err != nil {
+		zlog.Error(err.Error())
+		return nil
+	}
+	return &MessageBack{Message: jsonMessage, Uuid: uuid}
+}
+
+// appendToListCache 将新消息追加到Redis中已缓存的消息列表
+// 缓存不存在时说明还没有人拉取过历史消息，无需处理，下次拉取时会从数据库重建
+func appendToListCache(key string, item interface{}) {
+	rspString, err := myredis.GetKeyNilIsErr(key)
+	if err != nil {
+		if !errors.Is(err, redis.Nil) { // 如果不是因为键不存在导致的错误
+			zlog.Error(err.Error())
+		}
+		return
+	}
+
+	var rsp []json.RawMessage
+	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	itemByte, err := json.Marshal(item)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	rsp = append(rsp, itemByte)
+
+	rspByte, err := json.Marshal(rsp)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if err := myredis.SetKeyEx(key, string(rspByte), time.Minute*constants.REDIS_TIMEOUT); err != nil {
+		zlog.Error(err.Error())
+	}
+}
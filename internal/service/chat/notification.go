@@ -0,0 +1,38 @@
+package chat
+
+import (
+	"encoding/json"
+	"gochat/internal/dto/respond"
+	myKafka "gochat/internal/service/kafka"
+	"gochat/pkg/zlog"
+)
+
+// PushNotification 通过WebSocket向用户推送系统通知
+// 用户不在线时不做处理，通知已保存在收件箱中，用户上线后通过通知列表拉取
+func PushNotification(userId string, notification respond.NotificationRespond) {
+	frame, err := json.Marshal(respond.NotificationFrameRespond{
+		Frame:        "notification",
+		Notification: notification,
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	pushFrame(userId, frame)
+}
+
+// pushFrame 向用户下发服务端产生的帧，如系统通知和通话状态
+// kafka模式下通过会话事件广播，由持有该用户连接的节点下发
+func pushFrame(userId string, frame []byte) {
+	if messageMode == "channel" {
+		ChatServer.Clients.Send(userId, &MessageBack{Message: frame})
+		return
+	}
+	event := myKafka.NewSessionEvent(myKafka.SessionEventNotify, userId, "")
+	event.Payload = frame
+	if err := myKafka.KafkaService.PublishSessionEvent(ctx, event); err != nil {
+		// 事件发布失败时至少尝试在本节点下发
+		zlog.Error("发布通知事件失败：" + err.Error())
+		applySessionEvent(event)
+	}
+}
@@ -0,0 +1,62 @@
+package chat
+
+import (
+	"sync"
+
+	kafkaGo "github.com/segmentio/kafka-go"
+)
+
+// partitionOffsets 一个分区中已读取但尚未提交的偏移量
+type partitionOffsets struct {
+	topic   string
+	pending []int64        // 按读取顺序排列的未完成偏移量
+	done    map[int64]bool // 已处理完毕、但前面还有未完成消息的偏移量
+}
+
+// offsetTracker 跟踪Kafka消息的处理进度，计算可以安全提交的偏移量
+// 流水线中不同会话的消息并行处理，完成顺序与读取顺序不一致；
+// 提交偏移量意味着之前的消息都已处理，所以只能提交每个分区中连续完成的最大偏移量
+type offsetTracker struct {
+	mutex      sync.Mutex
+	partitions map[int]*partitionOffsets
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[int]*partitionOffsets)}
+}
+
+// track 登记一条刚读取的消息
+// 消费组重新分配分区后会从已提交的偏移量重新读取，此时丢弃该分区原有的记录，重复的消息由去重逻辑处理
+func (t *offsetTracker) track(message kafkaGo.Message) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	offsets, ok := t.partitions[message.Partition]
+	if !ok || (len(offsets.pending) > 0 && message.Offset <= offsets.pending[len(offsets.pending)-1]) {
+		offsets = &partitionOffsets{topic: message.Topic, done: make(map[int64]bool)}
+		t.partitions[message.Partition] = offsets
+	}
+	offsets.pending = append(offsets.pending, message.Offset)
+}
+
+// complete 标记消息处理完毕
+// 返回可以提交的消息位置，false表示该分区前面还有未处理完的消息，暂时不能提交
+func (t *offsetTracker) complete(message kafkaGo.Message) (kafkaGo.Message, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	offsets, ok := t.partitions[message.Partition]
+	// 分区记录已被重置时，旧记录中的消息不再参与提交
+	if !ok || len(offsets.pending) == 0 || message.Offset < offsets.pending[0] || message.Offset > offsets.pending[len(offsets.pending)-1] {
+		return kafkaGo.Message{}, false
+	}
+	offsets.done[message.Offset] = true
+	committed := int64(-1)
+	for len(offsets.pending) > 0 && offsets.done[offsets.pending[0]] {
+		committed = offsets.pending[0]
+		delete(offsets.done, committed)
+		offsets.pending = offsets.pending[1:]
+	}
+	if committed < 0 {
+		return kafkaGo.Message{}, false
+	}
+	return kafkaGo.Message{Topic: offsets.topic, Partition: message.Partition, Offset: committed}, true
+}
@@ -0,0 +1,57 @@
+package chat
+
+import (
+	"testing"
+
+	kafkaGo "github.com/segmentio/kafka-go"
+)
+
+func TestOffsetTrackerCommitsContiguousPrefix(t *testing.T) {
+	tracker := newOffsetTracker()
+	messages := make([]kafkaGo.Message, 4)
+	for i := range messages {
+		messages[i] = kafkaGo.Message{Topic: "chat", Partition: 0, Offset: int64(10 + i)}
+		tracker.track(messages[i])
+	}
+
+	// 后面的消息先处理完时不能提交
+	if _, ok := tracker.complete(messages[2]); ok {
+		t.Fatal("complete(12) committed before 10 and 11 finished")
+	}
+	if got, ok := tracker.complete(messages[0]); !ok || got.Offset != 10 || got.Topic != "chat" {
+		t.Fatalf("complete(10) = (%+v, %v), want offset 10", got, ok)
+	}
+	// 11处理完后，10到12连续完成
+	if got, ok := tracker.complete(messages[1]); !ok || got.Offset != 12 {
+		t.Fatalf("complete(11) = (%+v, %v), want offset 12", got, ok)
+	}
+	if got, ok := tracker.complete(messages[3]); !ok || got.Offset != 13 {
+		t.Fatalf("complete(13) = (%+v, %v), want offset 13", got, ok)
+	}
+}
+
+func TestOffsetTrackerPartitionsAreIndependent(t *testing.T) {
+	tracker := newOffsetTracker()
+	first := kafkaGo.Message{Partition: 0, Offset: 5}
+	second := kafkaGo.Message{Partition: 1, Offset: 7}
+	tracker.track(first)
+	tracker.track(second)
+	if got, ok := tracker.complete(second); !ok || got.Partition != 1 || got.Offset != 7 {
+		t.Fatalf("complete(partition 1) = (%+v, %v), want partition 1 offset 7", got, ok)
+	}
+}
+
+func TestOffsetTrackerResetsAfterRewind(t *testing.T) {
+	tracker := newOffsetTracker()
+	stale := kafkaGo.Message{Partition: 0, Offset: 20}
+	tracker.track(stale)
+	// 分区重新分配后从更早的偏移量重新读取
+	rewound := kafkaGo.Message{Partition: 0, Offset: 15}
+	tracker.track(rewound)
+	if _, ok := tracker.complete(stale); ok {
+		t.Fatal("complete() committed a message tracked before the rewind")
+	}
+	if got, ok := tracker.complete(rewound); !ok || got.Offset != 15 {
+		t.Fatalf("complete(15) = (%+v, %v), want offset 15", got, ok)
+	}
+}
@@ -0,0 +1,187 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"gochat/internal/dto/request"
+	"gochat/internal/dto/respond"
+	"gochat/pkg/zlog"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pipelineTask 流水线中的一个处理任务
+type pipelineTask struct {
+	run      func()
+	queuedAt time.Time
+}
+
+// pipeline 按会话分区的消息处理流水线
+// 同一会话的消息总是哈希到同一个worker，保证会话内按到达顺序处理；不同会话由不同worker并行处理
+type pipeline struct {
+	name      string
+	queues    []chan pipelineTask
+	wg        sync.WaitGroup
+	startOnce sync.Once
+	processed uint64 // 已处理的消息数
+	failed    uint64 // 处理时发生panic的消息数
+	waitNanos uint64 // 消息在队列中等待的累计时间
+}
+
+// newPipeline 创建消息处理流水线
+// 参数:
+//   - name: 流水线名称，用于日志
+//   - workers: worker数量，小于等于0时为1
+//   - queueSize: 每个worker队列的长度，小于等于0时不缓冲
+func newPipeline(name string, workers int, queueSize int) *pipeline {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	p := &pipeline{name: name, queues: make([]chan pipelineTask, workers)}
+	for i := range p.queues {
+		p.queues[i] = make(chan pipelineTask, queueSize)
+	}
+	return p
+}
+
+// start 启动所有worker，重复调用无效
+func (p *pipeline) start() {
+	p.startOnce.Do(func() {
+		for i, queue := range p.queues {
+			p.wg.Add(1)
+			go p.work(i, queue)
+		}
+	})
+}
+
+// work worker主循环，队列关闭后处理完剩余任务再退出
+func (p *pipeline) work(index int, queue chan pipelineTask) {
+	defer p.wg.Done()
+	for task := range queue {
+		atomic.AddUint64(&p.waitNanos, uint64(time.Since(task.queuedAt)))
+		p.runTask(index, task)
+	}
+}
+
+// runTask 执行单个任务，任务panic时只影响当前消息，worker继续运行
+func (p *pipeline) runTask(index int, task pipelineTask) {
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddUint64(&p.failed, 1)
+			zlog.Error(fmt.Sprintf("%s pipeline worker %d panic: %v", p.name, index, r))
+		}
+	}()
+	task.run()
+	atomic.AddUint64(&p.processed, 1)
+}
+
+// submit 将任务提交给会话对应的worker
+// 对应worker队列已满时阻塞，压力会传导到上游的转发通道或Kafka读取
+func (p *pipeline) submit(key string, run func()) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	p.queues[h.Sum32()%uint32(len(p.queues))] <- pipelineTask{run: run, queuedAt: time.Now()}
+}
+
+// stop 关闭所有worker队列并等待队列中的任务处理完毕
+// 调用方需保证stop之后不再提交任务
+func (p *pipeline) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// stopContext 与stop相同，但最多等待到ctx的期限
+func (p *pipeline) stopContext(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// conversationKey 计算消息所属会话，作为流水线的分区键
+// 群聊以群组ID为会话，私聊以排序后的双方ID为会话，保证双方互发的消息也在同一个worker中处理
+func conversationKey(chatMessageReq request.ChatMessageRequest) string {
+	if strings.HasPrefix(chatMessageReq.ReceiveId, "G") {
+		return chatMessageReq.ReceiveId
+	}
+	if chatMessageReq.SendId < chatMessageReq.ReceiveId {
+		return chatMessageReq.SendId + "_" + chatMessageReq.ReceiveId
+	}
+	return chatMessageReq.ReceiveId + "_" + chatMessageReq.SendId
+}
+
+// stats 获取流水线的队列深度和处理计数
+func (p *pipeline) stats() respond.PipelineStatsRespond {
+	depths := make([]int, len(p.queues))
+	totalDepth := 0
+	for i, queue := range p.queues {
+		depths[i] = len(queue)
+		totalDepth += depths[i]
+	}
+	processed := atomic.LoadUint64(&p.processed)
+	failed := atomic.LoadUint64(&p.failed)
+	var avgWaitMs float64
+	if handled := processed + failed; handled > 0 {
+		avgWaitMs = float64(atomic.LoadUint64(&p.waitNanos)) / float64(handled) / float64(time.Millisecond)
+	}
+	return respond.PipelineStatsRespond{
+		Name:        p.name,
+		Workers:     len(p.queues),
+		QueueSize:   cap(p.queues[0]),
+		QueueDepths: depths,
+		TotalDepth:  totalDepth,
+		Processed:   processed,
+		Failed:      failed,
+		AvgWaitMs:   avgWaitMs,
+	}
+}
+
+// reportStats 按固定间隔将队列深度输出到日志，interval小于等于0时不输出
+func (p *pipeline) reportStats(interval time.Duration, extra func() string) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		stats := p.stats()
+		zlog.Info(fmt.Sprintf("%s pipeline: workers=%d depth=%d max=%d processed=%d failed=%d avgWait=%.2fms%s",
+			stats.Name, stats.Workers, stats.TotalDepth, maxDepth(stats.QueueDepths), stats.Processed, stats.Failed, stats.AvgWaitMs, extra()))
+	}
+}
+
+// maxDepth 最繁忙worker的队列深度，用于发现热点会话
+func maxDepth(depths []int) int {
+	max := 0
+	for _, depth := range depths {
+		if depth > max {
+			max = depth
+		}
+	}
+	return max
+}
+
+// GetPipelineStats 获取当前消息模式下的流水线指标
+func GetPipelineStats() (string, respond.PipelineStatsRespond, int) {
+	if messageMode == "channel" {
+		stats := ChatServer.pipeline.stats()
+		stats.TransmitDepth = len(ChatServer.Transmit)
+		return "获取流水线指标成功", stats, 0
+	}
+	return "获取流水线指标成功", KafkaChatServer.pipeline.stats(), 0
+}
@@ -0,0 +1,196 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gochat/internal/config"
+	"gochat/internal/dto/respond"
+	myKafka "gochat/internal/service/kafka"
+	myredis "gochat/internal/service/redis"
+	"gochat/pkg/zlog"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// presenceRecord 用户当前的连接位置
+type presenceRecord struct {
+	nodeId string // 持有该用户连接的节点ID
+	connId string // 连接ID，同一用户在同一节点重连后会变化
+}
+
+// presence 记录集群中每个在线用户所在的节点
+// kafka模式下由各节点广播的会话事件维护，channel模式下只有本节点的连接
+type presence struct {
+	mutex *sync.RWMutex
+	nodes map[string]presenceRecord // key为用户UUID
+}
+
+// onlinePresence 全局在线状态表
+var onlinePresence = &presence{
+	mutex: &sync.RWMutex{},
+	nodes: make(map[string]presenceRecord),
+}
+
+// online 标记用户的连接在指定节点上线
+func (p *presence) online(userId string, nodeId string, connId string) {
+	p.mutex.Lock()
+	p.nodes[userId] = presenceRecord{nodeId: nodeId, connId: connId}
+	p.mutex.Unlock()
+}
+
+// offline 标记用户的连接从指定节点下线
+// 只有记录的仍是该连接时才删除，避免旧连接迟到的下线事件覆盖用户的新连接，新连接在同一节点上也不受影响
+func (p *presence) offline(userId string, nodeId string, connId string) {
+	p.mutex.Lock()
+	if p.nodes[userId] == (presenceRecord{nodeId: nodeId, connId: connId}) {
+		delete(p.nodes, userId)
+	}
+	p.mutex.Unlock()
+}
+
+// remove 无条件删除用户的在线记录，用于强制下线
+func (p *presence) remove(userId string) {
+	p.mutex.Lock()
+	delete(p.nodes, userId)
+	p.mutex.Unlock()
+}
+
+// isOnline 判断用户是否在集群中的任意节点在线
+func (p *presence) isOnline(userId string) bool {
+	p.mutex.RLock()
+	_, ok := p.nodes[userId]
+	p.mutex.RUnlock()
+	return ok
+}
+
+// GetOnlineStatus 查询一批用户的在线状态
+func GetOnlineStatus(uuidList []string) (string, []respond.GetOnlineStatusRespond, int) {
+	rspList := make([]respond.GetOnlineStatusRespond, 0, len(uuidList))
+	for _, uuid := range uuidList {
+		rspList = append(rspList, respond.GetOnlineStatusRespond{
+			UserId:   uuid,
+			IsOnline: onlinePresence.isOnline(uuid),
+		})
+	}
+	return "获取在线状态成功", rspList, 0
+}
+
+// KickUser 强制用户下线
+// kafka模式下发布kick事件，由持有该用户连接的节点断开连接；channel模式下直接断开本节点的连接
+func KickUser(userId string, reason string) {
+	if messageMode == "channel" {
+		onlinePresence.remove(userId)
+		ChatServer.kickClient(userId, reason)
+		invalidateUserCache(userId)
+		return
+	}
+	event := myKafka.NewSessionEvent(myKafka.SessionEventKick, userId, reason)
+	if err := myKafka.KafkaService.PublishSessionEvent(ctx, event); err != nil {
+		// 事件发布失败时至少保证本节点的连接被断开
+		zlog.Error("发布强制下线事件失败：" + err.Error())
+		onlinePresence.remove(userId)
+		KafkaChatServer.kickClient(userId, reason)
+		invalidateUserCache(userId)
+	}
+}
+
+// invalidateUserCache 清理被强制下线用户的会话和联系人缓存
+func invalidateUserCache(userId string) {
+	for _, key := range []string{
+		"session_list_" + userId,
+		"group_session_list_" + userId,
+		"contact_user_list_" + userId,
+		"my_joined_group_list_" + userId,
+		"contact_mygroup_list_" + userId,
+	} {
+		if err := myredis.DelKeyIfExists(key); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+}
+
+// publishSessionEvent 发布当前节点上客户端连接产生的上下线事件，并同步更新本节点的在线状态快照
+// 发布失败时直接更新本地在线状态，其他节点的状态会在用户下一次上下线时修正
+func publishSessionEvent(eventType string, client *Client) {
+	if eventType == myKafka.SessionEventConnect {
+		saveClientSnapshot(client)
+	} else {
+		dropClientSnapshot(client)
+	}
+	event := myKafka.NewSessionEvent(eventType, client.Uuid, "")
+	event.ConnId = client.ConnId
+	if err := myKafka.KafkaService.PublishSessionEvent(ctx, event); err != nil {
+		zlog.Error("发布会话事件失败：" + err.Error())
+		applySessionEvent(event)
+	}
+}
+
+// 读取会话事件失败后的重试间隔，每次失败翻倍，直到上限
+const (
+	sessionEventRetryMin = 100 * time.Millisecond
+	sessionEventRetryMax = 5 * time.Second
+)
+
+// consumeSessionEvents 持续消费会话主题中的会话事件
+// 每个节点使用独立的消费组，所有节点都会处理全部事件，包括自己发布的事件
+// 读取失败时退避重试，只有读取器被关闭或上下文结束时才停止消费
+func consumeSessionEvents(reader *kafka.Reader) {
+	backoff := sessionEventRetryMin
+	for {
+		kafkaMessage, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return // 读取器被关闭或上下文结束，停止消费
+			}
+			zlog.Error("读取会话事件失败：" + err.Error())
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > sessionEventRetryMax {
+				backoff = sessionEventRetryMax
+			}
+			continue
+		}
+		backoff = sessionEventRetryMin
+		var event myKafka.SessionEvent
+		if err := json.Unmarshal(kafkaMessage.Value, &event); err != nil {
+			zlog.Error("会话事件解析失败：" + err.Error())
+			continue
+		}
+		safeApplySessionEvent(event)
+	}
+}
+
+// safeApplySessionEvent 处理单个会话事件，处理时panic只影响当前事件，消费继续进行
+func safeApplySessionEvent(event myKafka.SessionEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			zlog.Error(fmt.Sprintf("session event consumer panic: %v", r))
+		}
+	}()
+	applySessionEvent(event)
+}
+
+// applySessionEvent 根据会话事件更新在线状态，kick事件还会断开本节点上该用户的连接，notify事件向本节点上该用户的连接下发通知
+func applySessionEvent(event myKafka.SessionEvent) {
+	switch event.Type {
+	case myKafka.SessionEventConnect:
+		onlinePresence.online(event.UserId, event.NodeId, event.ConnId)
+	case myKafka.SessionEventDisconnect:
+		onlinePresence.offline(event.UserId, event.NodeId, event.ConnId)
+	case myKafka.SessionEventKick:
+		onlinePresence.remove(event.UserId)
+		KafkaChatServer.kickClient(event.UserId, event.Reason)
+		// Redis缓存由集群共享，只需由发起强制下线的节点清理一次
+		if event.NodeId == config.GetConfig().NodeId {
+			invalidateUserCache(event.UserId)
+		}
+		zlog.Info(fmt.Sprintf("节点%s收到强制下线事件，用户%s，来源节点%s", config.GetConfig().NodeId, event.UserId, event.NodeId))
+	case myKafka.SessionEventNotify:
+		KafkaChatServer.Clients.Send(event.UserId, &MessageBack{Message: event.Payload})
+	default:
+		zlog.Warn("未知的会话事件类型：" + event.Type)
+	}
+}
@@ -0,0 +1,95 @@
+package chat
+
+import (
+	"fmt"
+	"gochat/internal/config"
+	myredis "gochat/internal/service/redis"
+	"gochat/pkg/zlog"
+	"strings"
+	"time"
+)
+
+// 在线状态快照的刷新间隔和保留时间
+// 每个节点在Redis中维护一份本节点连接的快照，节点异常退出后快照随过期时间自然淘汰
+const (
+	presenceSnapshotInterval = 10 * time.Second
+	presenceSnapshotExpire   = 3 * presenceSnapshotInterval
+)
+
+// presenceSnapshotPrefix 节点在线状态快照的Redis键前缀，哈希表的字段为用户UUID，值为连接ID
+const presenceSnapshotPrefix = "presence_node_"
+
+// presenceSnapshotKey 指定节点的在线状态快照键
+func presenceSnapshotKey(nodeId string) string {
+	return presenceSnapshotPrefix + nodeId
+}
+
+// saveClientSnapshot 将本节点新建立的连接写入快照
+func saveClientSnapshot(client *Client) {
+	if err := myredis.SetHashField(presenceSnapshotKey(config.GetConfig().NodeId), client.Uuid, client.ConnId, presenceSnapshotExpire); err != nil {
+		zlog.Error("写入在线状态快照失败：" + err.Error())
+	}
+}
+
+// dropClientSnapshot 从快照中删除本节点已断开的连接，用户已在本节点重新连接时保留新连接
+func dropClientSnapshot(client *Client) {
+	if err := myredis.DelHashFieldIfValue(presenceSnapshotKey(config.GetConfig().NodeId), client.Uuid, client.ConnId); err != nil {
+		zlog.Error("删除在线状态快照失败：" + err.Error())
+	}
+}
+
+// refreshPresenceSnapshot 定期用本节点的全部连接重写快照并续期
+// 上下线时的单条写入失败也会在下一次刷新时修正
+func refreshPresenceSnapshot(clients *ClientRegistry) {
+	ticker := time.NewTicker(presenceSnapshotInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if IsShuttingDown() {
+			return
+		}
+		fields := make(map[string]string)
+		clients.Range(func(client *Client) bool {
+			fields[client.Uuid] = client.ConnId
+			return true
+		})
+		if err := myredis.ReplaceHash(presenceSnapshotKey(config.GetConfig().NodeId), fields, presenceSnapshotExpire); err != nil {
+			zlog.Error("刷新在线状态快照失败：" + err.Error())
+		}
+	}
+}
+
+// loadPresenceSnapshot 节点启动时从其他节点的快照恢复集群在线状态
+// 会话事件从最新位置开始消费，启动前已经连接到其他节点的用户只能从快照中得知
+func loadPresenceSnapshot() {
+	keys, err := myredis.GetKeysWithPrefix(presenceSnapshotPrefix)
+	if err != nil {
+		zlog.Error("读取在线状态快照失败：" + err.Error())
+		return
+	}
+	selfKey := presenceSnapshotKey(config.GetConfig().NodeId)
+	nodes, loaded := 0, 0
+	for _, key := range keys {
+		if key == selfKey {
+			continue
+		}
+		snapshot, err := myredis.GetHashAll(key)
+		if err != nil {
+			zlog.Error("读取在线状态快照失败：" + err.Error())
+			continue
+		}
+		nodeId := strings.TrimPrefix(key, presenceSnapshotPrefix)
+		for userId, connId := range snapshot {
+			onlinePresence.online(userId, nodeId, connId)
+		}
+		nodes++
+		loaded += len(snapshot)
+	}
+	zlog.Info(fmt.Sprintf("已从%d个节点的快照恢复%d个在线用户", nodes, loaded))
+}
+
+// clearPresenceSnapshot 节点关闭时删除本节点的快照
+func clearPresenceSnapshot() {
+	if err := myredis.DelKeys(presenceSnapshotKey(config.GetConfig().NodeId)); err != nil {
+		zlog.Error("删除在线状态快照失败：" + err.Error())
+	}
+}
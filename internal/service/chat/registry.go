@@ -0,0 +1,214 @@
+package chat
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// registryShardCount 注册表分片数量，为2的幂，便于用位运算定位分片
+const registryShardCount = 64
+
+// registryShard 注册表分片，每个分片有独立的读写锁
+type registryShard struct {
+	mutex   sync.RWMutex
+	clients map[string]*Client
+}
+
+// ClientRegistry 分片的客户端连接注册表
+// 按用户UUID哈希到不同分片，查找和投递只持有对应分片的读锁，不同用户的消息互不阻塞
+// 投递期间持有读锁，关闭客户端下行队列需要持有写锁，保证不会向已关闭的队列发送消息
+type ClientRegistry struct {
+	shards [registryShardCount]*registryShard
+	// onEvict 客户端因接收过慢或连接断开被移除后的回调，用于更新在线状态
+	onEvict func(client *Client)
+}
+
+// NewClientRegistry 创建客户端注册表
+// 参数:
+//   - onEvict: 客户端因接收过慢或连接断开被移除后的回调，可以为nil
+func NewClientRegistry(onEvict func(client *Client)) *ClientRegistry {
+	r := &ClientRegistry{onEvict: onEvict}
+	for i := range r.shards {
+		r.shards[i] = &registryShard{clients: make(map[string]*Client)}
+	}
+	return r
+}
+
+// shard 定位用户所在的分片
+func (r *ClientRegistry) shard(uuid string) *registryShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(uuid))
+	return r.shards[h.Sum32()&(registryShardCount-1)]
+}
+
+// Add 注册客户端，同一用户已有连接时断开旧连接，返回是否替换了旧连接
+func (r *ClientRegistry) Add(client *Client) bool {
+	shard := r.shard(client.Uuid)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	old, ok := shard.clients[client.Uuid]
+	shard.clients[client.Uuid] = client
+	if ok && old != client {
+		old.forceClose(replacedFrame, websocket.CloseNormalClosure)
+	}
+	return ok
+}
+
+// Get 查找用户在本节点的连接
+func (r *ClientRegistry) Get(uuid string) (*Client, bool) {
+	shard := r.shard(uuid)
+	shard.mutex.RLock()
+	client, ok := shard.clients[uuid]
+	shard.mutex.RUnlock()
+	return client, ok
+}
+
+// Len 本节点的连接数量
+func (r *ClientRegistry) Len() int {
+	count := 0
+	for _, shard := range r.shards {
+		shard.mutex.RLock()
+		count += len(shard.clients)
+		shard.mutex.RUnlock()
+	}
+	return count
+}
+
+// Range 遍历所有连接，fn返回false时停止遍历
+// 遍历时持有分片读锁，fn中不能调用注册表的写操作
+func (r *ClientRegistry) Range(fn func(client *Client) bool) {
+	for _, shard := range r.shards {
+		shard.mutex.RLock()
+		for _, client := range shard.clients {
+			if !fn(client) {
+				shard.mutex.RUnlock()
+				return
+			}
+		}
+		shard.mutex.RUnlock()
+	}
+}
+
+// Remove 注销客户端，只有注册的仍是该连接时才移除，避免误删同一用户的新连接
+// 返回是否移除成功
+func (r *ClientRegistry) Remove(client *Client) bool {
+	shard := r.shard(client.Uuid)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	if shard.clients[client.Uuid] != client {
+		return false
+	}
+	delete(shard.clients, client.Uuid)
+	return true
+}
+
+// Close 注销客户端并关闭其下行队列，frame不为nil时会在关闭前送出
+// 只有成功注销的调用方会关闭队列，保证队列只被关闭一次
+func (r *ClientRegistry) Close(client *Client, frame []byte) bool {
+	shard := r.shard(client.Uuid)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	if shard.clients[client.Uuid] != client {
+		return false
+	}
+	delete(shard.clients, client.Uuid)
+	client.forceClose(frame, websocket.CloseNormalClosure)
+	return true
+}
+
+// CloseAll 注销并关闭本节点的所有连接，返回被关闭的客户端
+// 参数:
+//   - frame: 关闭前送出的提示帧，可以为nil
+//   - closeCode: 发送给客户端的WebSocket关闭码
+func (r *ClientRegistry) CloseAll(frame []byte, closeCode int) []*Client {
+	var closed []*Client
+	for _, shard := range r.shards {
+		shard.mutex.Lock()
+		for uuid, client := range shard.clients {
+			delete(shard.clients, uuid)
+			client.forceClose(frame, closeCode)
+			closed = append(closed, client)
+		}
+		shard.mutex.Unlock()
+	}
+	return closed
+}
+
+// CloseByUuid 按用户UUID注销并关闭连接，用户不在本节点时返回nil
+func (r *ClientRegistry) CloseByUuid(uuid string, frame []byte) *Client {
+	client, ok := r.Get(uuid)
+	if !ok || !r.Close(client, frame) {
+		return nil
+	}
+	return client
+}
+
+// Send 向单个用户投递消息，返回用户是否在本节点
+func (r *ClientRegistry) Send(uuid string, messageBack *MessageBack) bool {
+	shard := r.shard(uuid)
+	shard.mutex.RLock()
+	client, ok := shard.clients[uuid]
+	evict := ok && deliver(client, messageBack)
+	shard.mutex.RUnlock()
+	if evict {
+		r.evict(client)
+	}
+	return ok
+}
+
+// Broadcast 向一组用户投递同一条消息，返回在本节点投递的用户数
+// 先按分片归类，每个分片只加一次读锁，千人群的广播不会频繁加解锁
+func (r *ClientRegistry) Broadcast(uuidList []string, messageBack *MessageBack) int {
+	var buckets [registryShardCount][]string
+	for _, uuid := range uuidList {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(uuid))
+		index := h.Sum32() & (registryShardCount - 1)
+		buckets[index] = append(buckets[index], uuid)
+	}
+
+	delivered := 0
+	var evicted []*Client
+	for index, bucket := range buckets {
+		if len(bucket) == 0 {
+			continue
+		}
+		shard := r.shards[index]
+		shard.mutex.RLock()
+		for _, uuid := range bucket {
+			if client, ok := shard.clients[uuid]; ok {
+				delivered++
+				if deliver(client, messageBack) {
+					evicted = append(evicted, client)
+				}
+			}
+		}
+		shard.mutex.RUnlock()
+	}
+	// 读锁释放后再断开慢消费者，关闭下行队列需要写锁
+	for _, client := range evicted {
+		r.evict(client)
+	}
+	return delivered
+}
+
+// Disconnect 客户端连接断开后注销客户端，并与断开慢消费者一样触发下线回调
+// 连接已被替换或已被服务器关闭时不做处理，返回是否注销成功
+func (r *ClientRegistry) Disconnect(client *Client) bool {
+	if !r.Close(client, nil) {
+		return false
+	}
+	if r.onEvict != nil {
+		r.onEvict(client)
+	}
+	return true
+}
+
+// evict 断开接收过慢的客户端
+func (r *ClientRegistry) evict(client *Client) {
+	if r.Close(client, slowConsumerFrame) && r.onEvict != nil {
+		r.onEvict(client)
+	}
+}
@@ -0,0 +1,186 @@
+package chat
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 基准测试的规模：已连接的客户端数量、群聊成员数量、每个客户端下行队列的长度
+const (
+	benchClientCount = 10000
+	benchGroupSize   = 1000
+	benchQueueSize   = 100
+)
+
+// mutexRegistry 原先的实现：单个互斥锁保护整个映射，投递时持有锁，作为分片注册表的对照
+type mutexRegistry struct {
+	mutex   sync.Mutex
+	clients map[string]*Client
+}
+
+func (m *mutexRegistry) get(uuid string) (*Client, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	client, ok := m.clients[uuid]
+	return client, ok
+}
+
+func (m *mutexRegistry) send(uuid string, messageBack *MessageBack) {
+	m.mutex.Lock()
+	if client, ok := m.clients[uuid]; ok {
+		select {
+		case client.SendBack <- messageBack:
+		default:
+		}
+	}
+	m.mutex.Unlock()
+}
+
+func (m *mutexRegistry) broadcast(uuidList []string, messageBack *MessageBack) {
+	m.mutex.Lock()
+	for _, uuid := range uuidList {
+		if client, ok := m.clients[uuid]; ok {
+			select {
+			case client.SendBack <- messageBack:
+			default:
+			}
+		}
+	}
+	m.mutex.Unlock()
+}
+
+// benchFixture 两种注册表注册相同的客户端，每个客户端由一个goroutine持续消费下行队列，模拟写goroutine
+type benchFixture struct {
+	sharded  *ClientRegistry
+	locked   *mutexRegistry
+	uuidList []string
+	members  []string
+	stop     func()
+}
+
+func newBenchFixture(b *testing.B) *benchFixture {
+	b.Helper()
+	f := &benchFixture{
+		sharded:  NewClientRegistry(nil),
+		locked:   &mutexRegistry{clients: make(map[string]*Client, benchClientCount)},
+		uuidList: make([]string, benchClientCount),
+	}
+	var wg sync.WaitGroup
+	clients := make([]*Client, benchClientCount)
+	for i := range clients {
+		client := &Client{
+			Uuid:     fmt.Sprintf("U%010d", i),
+			SendBack: make(chan *MessageBack, benchQueueSize),
+		}
+		clients[i] = client
+		f.uuidList[i] = client.Uuid
+		f.sharded.Add(client)
+		f.locked.clients[client.Uuid] = client
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range client.SendBack {
+			}
+		}()
+	}
+	// 群成员从所有客户端中随机选取
+	for _, i := range rand.Perm(benchClientCount)[:benchGroupSize] {
+		f.members = append(f.members, f.uuidList[i])
+	}
+	f.stop = func() {
+		for _, client := range clients {
+			close(client.SendBack)
+		}
+		wg.Wait()
+	}
+	b.Cleanup(f.stop)
+	return f
+}
+
+// runParallel 并发执行fn，每个goroutine使用独立的随机数生成器
+func runParallel(b *testing.B, fn func(r *rand.Rand)) {
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		for pb.Next() {
+			fn(r)
+		}
+	})
+}
+
+func BenchmarkRegistryLookup(b *testing.B) {
+	f := newBenchFixture(b)
+	b.Run("sharded", func(b *testing.B) {
+		runParallel(b, func(r *rand.Rand) {
+			f.sharded.Get(f.uuidList[r.Intn(len(f.uuidList))])
+		})
+	})
+	b.Run("mutex", func(b *testing.B) {
+		runParallel(b, func(r *rand.Rand) {
+			f.locked.get(f.uuidList[r.Intn(len(f.uuidList))])
+		})
+	})
+}
+
+func BenchmarkRegistryDirectSend(b *testing.B) {
+	f := newBenchFixture(b)
+	messageBack := &MessageBack{Message: []byte(`{"content":"bench"}`)}
+	b.Run("sharded", func(b *testing.B) {
+		runParallel(b, func(r *rand.Rand) {
+			f.sharded.Send(f.uuidList[r.Intn(len(f.uuidList))], messageBack)
+		})
+	})
+	b.Run("mutex", func(b *testing.B) {
+		runParallel(b, func(r *rand.Rand) {
+			f.locked.send(f.uuidList[r.Intn(len(f.uuidList))], messageBack)
+		})
+	})
+}
+
+func BenchmarkRegistryGroupBroadcast(b *testing.B) {
+	f := newBenchFixture(b)
+	messageBack := &MessageBack{Message: []byte(`{"content":"bench"}`)}
+	b.Run("sharded", func(b *testing.B) {
+		runParallel(b, func(r *rand.Rand) {
+			f.sharded.Broadcast(f.members, messageBack)
+		})
+		b.ReportMetric(float64(b.N*len(f.members))/b.Elapsed().Seconds(), "deliveries/s")
+	})
+	b.Run("mutex", func(b *testing.B) {
+		runParallel(b, func(r *rand.Rand) {
+			f.locked.broadcast(f.members, messageBack)
+		})
+		b.ReportMetric(float64(b.N*len(f.members))/b.Elapsed().Seconds(), "deliveries/s")
+	})
+}
+
+func TestRegistryCloseKeepsFrameWhenQueueFull(t *testing.T) {
+	r := NewClientRegistry(nil)
+	client := &Client{Uuid: "U0000000001", SendBack: make(chan *MessageBack, 1)}
+	r.Add(client)
+	client.SendBack <- &MessageBack{Message: []byte("queued")}
+
+	frame := []byte("closing")
+	if !r.Close(client, frame) {
+		t.Fatal("Close() = false, want true")
+	}
+	// 关闭后读goroutine仍可能回送提示，不能向已关闭的队列发送
+	client.sendFeedback([]byte("late"))
+
+	var queued int
+	for range client.SendBack {
+		queued++
+	}
+	if queued != 1 {
+		t.Errorf("queued messages = %d, want 1", queued)
+	}
+	if string(client.closeFrame) != string(frame) {
+		t.Errorf("closeFrame = %q, want %q", client.closeFrame, frame)
+	}
+	if r.Close(client, frame) {
+		t.Error("second Close() = true, want false")
+	}
+}
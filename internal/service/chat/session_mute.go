@@ -0,0 +1,40 @@
+package chat
+
+import (
+	"gochat/internal/dao"
+	"gochat/internal/model"
+	"gochat/pkg/zlog"
+)
+
+// mutedSessionOwners 返回owners中对与peerId的会话开启了免打扰的用户
+// 私聊时peerId为发送者ID，群聊时为群聊ID；查询失败时按未开启处理
+func mutedSessionOwners(peerId string, owners []string) map[string]bool {
+	var mutedOwners []string
+	if res := dao.GormDB.Model(&model.Session{}).
+		Where("receive_id = ? AND is_muted = 1 AND send_id IN (?)", peerId, owners).
+		Pluck("send_id", &mutedOwners); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return nil
+	}
+	muted := make(map[string]bool, len(mutedOwners))
+	for _, owner := range mutedOwners {
+		muted[owner] = true
+	}
+	return muted
+}
+
+// splitMuted 将接收者按是否开启免打扰分成两组
+func splitMuted(peerId string, receivers []string) (normal []string, muted []string) {
+	mutedOwners := mutedSessionOwners(peerId, receivers)
+	if len(mutedOwners) == 0 {
+		return receivers, nil
+	}
+	for _, receiver := range receivers {
+		if mutedOwners[receiver] {
+			muted = append(muted, receiver)
+		} else {
+			normal = append(normal, receiver)
+		}
+	}
+	return normal, muted
+}
@@ -0,0 +1,103 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	myKafka "gochat/internal/service/kafka"
+	"gochat/pkg/enum/message/frame_error_enum"
+	"gochat/pkg/zlog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// shuttingDown 服务器是否正在关闭，1表示正在关闭
+var shuttingDown int32
+
+// IsShuttingDown 判断服务器是否正在关闭
+func IsShuttingDown() bool {
+	return atomic.LoadInt32(&shuttingDown) == 1
+}
+
+// goingAwayFrame 服务器关闭时回送给客户端的错误帧
+var goingAwayFrame = newErrorFrame(frame_error_enum.GOING_AWAY, "服务器正在关闭，请重新连接")
+
+// Shutdown 优雅关闭聊天服务
+// 1. 拒绝新的WebSocket连接和新的上行消息
+// 2. 等待转发通道（channel模式）或已读取的Kafka消息（kafka模式）在流水线中处理完毕
+// 3. 向每个客户端发送服务器关闭提示和关闭帧，等待写goroutine把剩余消息发送完毕
+// 4. kafka模式下广播本节点所有连接的下线事件，其他节点据此更新在线状态，并删除本节点的在线状态快照
+// 5. 结束或离开这些用户正在进行的通话，释放通话线路，否则用户要等到超过最长通话时间才能再次通话
+// 所有步骤共享ctx的期限，超时后跳过剩余的等待
+func Shutdown(ctx context.Context) {
+	atomic.StoreInt32(&shuttingDown, 1)
+
+	var clients *ClientRegistry
+	var err error
+	if messageMode == "channel" {
+		clients = ChatServer.Clients
+		err = ChatServer.drain(ctx)
+	} else {
+		clients = KafkaChatServer.Clients
+		err = KafkaChatServer.drain(ctx)
+	}
+	if err != nil {
+		zlog.Error("等待消息处理完毕超时：" + err.Error())
+	}
+
+	closed := clients.CloseAll(goingAwayFrame, websocket.CloseGoingAway)
+	zlog.Info(fmt.Sprintf("已通知%d个客户端重新连接", len(closed)))
+
+	if messageMode == "channel" {
+		for _, client := range closed {
+			onlinePresence.remove(client.Uuid)
+		}
+	} else {
+		events := make([]myKafka.SessionEvent, 0, len(closed))
+		for _, client := range closed {
+			event := myKafka.NewSessionEvent(myKafka.SessionEventDisconnect, client.Uuid, "")
+			event.ConnId = client.ConnId
+			events = append(events, event)
+		}
+		if err := myKafka.KafkaService.PublishSessionEvents(ctx, events...); err != nil {
+			zlog.Error("广播下线事件失败：" + err.Error())
+		}
+		clearPresenceSnapshot()
+	}
+
+	endClosedClientCalls(ctx, closed)
+
+	done := make(chan struct{})
+	go func() {
+		writers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		zlog.Error("等待客户端连接关闭超时：" + ctx.Err().Error())
+	}
+}
+
+// endClosedClientCalls 并发结束被关闭客户端的通话，最多等待到ctx的期限
+func endClosedClientCalls(ctx context.Context, closed []*Client) {
+	var wg sync.WaitGroup
+	for _, client := range closed {
+		wg.Add(1)
+		go func(userId string) {
+			defer wg.Done()
+			endUserCalls(userId)
+		}(client.Uuid)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		zlog.Error("等待结束通话超时：" + ctx.Err().Error())
+	}
+}
@@ -0,0 +1,279 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"gochat/internal/config"
+	"gochat/internal/dao"
+	"gochat/internal/dto/request"
+	"gochat/internal/model"
+	"gochat/pkg/enum/contact/contact_status_enum"
+	"gochat/pkg/enum/group_info/group_status_enum"
+	"gochat/pkg/enum/message/frame_error_enum"
+	"gochat/pkg/enum/message/message_type_enum"
+	"gochat/pkg/enum/user_info/user_status_enum"
+	"gochat/pkg/zlog"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// 与消息表字段长度一致的限制
+const (
+	maxUuidLength     = 20  // 用户和群聊uuid的最大长度
+	maxUrlLength      = 255 // 文件url的最大长度
+	maxFileNameLength = 50  // 文件名的最大字符数
+	maxFileTypeLength = 10  // 文件类型的最大长度
+	maxFileSizeLength = 20  // 文件大小描述的最大长度
+)
+
+// 未配置或配置为0时使用的默认值
+const (
+	defaultMaxTextLength   = 2000  // 文本消息的最大字符数
+	defaultMaxAVDataLength = 16384 // 通话信令数据的最大字节数
+)
+
+// maxTextLength 文本消息的最大字符数
+func maxTextLength() int {
+	if length := config.GetConfig().ChatConfig.MaxTextLength; length > 0 {
+		return length
+	}
+	return defaultMaxTextLength
+}
+
+// maxAVDataLength 通话信令数据的最大字节数
+func maxAVDataLength() int {
+	if length := config.GetConfig().ChatConfig.MaxAVDataLength; length > 0 {
+		return length
+	}
+	return defaultMaxAVDataLength
+}
+
+// messageRejection 消息未通过校验的原因，以错误帧的形式回送给发送者
+type messageRejection struct {
+	code    string // 错误码，见frame_error_enum
+	message string // 展示给用户的提示
+}
+
+func reject(code string, message string) *messageRejection {
+	return &messageRejection{code: code, message: message}
+}
+
+// prepareChatMessage 校验客户端上行的聊天消息，并用连接对应的用户身份覆盖发送者字段
+// 客户端提交的send_id、send_name、send_avatar一律不可信，以建立连接时认证的用户为准
+// 返回nil表示校验通过
+func prepareChatMessage(senderId string, req *request.ChatMessageRequest) *messageRejection {
+	if rejection := validateMessageFormat(req); rejection != nil {
+		return rejection
+	}
+
+	var sender model.UserInfo
+	if res := dao.GormDB.Select("uuid", "nickname", "avatar", "status").First(&sender, "uuid = ?", senderId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return reject(frame_error_enum.SENDER_DISABLED, "账号不存在或已被删除")
+		}
+		zlog.Error(res.Error.Error())
+		return reject(frame_error_enum.SEND_FAILED, "消息发送失败，请稍后重试")
+	}
+	if sender.Status == user_status_enum.DISABLE {
+		return reject(frame_error_enum.SENDER_DISABLED, "账号已被禁用，无法发送消息")
+	}
+	req.SendId = sender.Uuid
+	req.SendName = sender.Nickname
+	req.SendAvatar = sender.Avatar
+
+	if req.Type == message_type_enum.Voice {
+		if rejection := checkVoice(req); rejection != nil {
+			return rejection
+		}
+	}
+	if req.Type == message_type_enum.File {
+		if rejection := checkFile(req); rejection != nil {
+			return rejection
+		}
+	}
+
+	if req.Type == message_type_enum.AudioOrVideo {
+		return checkCallSignal(req)
+	}
+	if req.ReceiveId[0] == 'U' {
+		return checkContact(req.SendId, req.ReceiveId)
+	}
+	return checkGroupMember(req.SendId, req.ReceiveId)
+}
+
+// validateMessageFormat 校验消息的必要字段和长度限制，不访问数据库
+func validateMessageFormat(req *request.ChatMessageRequest) *messageRejection {
+	if req.ReceiveId == "" || len(req.ReceiveId) > maxUuidLength || (req.ReceiveId[0] != 'U' && req.ReceiveId[0] != 'G') {
+		return reject(frame_error_enum.INVALID_MESSAGE, "接收者不合法")
+	}
+	if len(req.ClientMessageId) > maxClientMessageIdLength {
+		return reject(frame_error_enum.INVALID_MESSAGE, "客户端消息ID过长")
+	}
+
+	switch req.Type {
+	case message_type_enum.Text:
+		if req.Content == "" {
+			return reject(frame_error_enum.INVALID_MESSAGE, "消息内容不能为空")
+		}
+		if utf8.RuneCountInString(req.Content) > maxTextLength() {
+			return reject(frame_error_enum.CONTENT_TOO_LONG, "消息内容过长")
+		}
+	case message_type_enum.Voice:
+		if req.Url == "" {
+			return reject(frame_error_enum.INVALID_MESSAGE, "语音地址不能为空")
+		}
+		if len(req.Url) > maxUrlLength || utf8.RuneCountInString(req.FileName) > maxFileNameLength ||
+			len(req.FileSize) > maxFileSizeLength {
+			return reject(frame_error_enum.CONTENT_TOO_LONG, "语音信息过长")
+		}
+		// 客户端提交的格式只用于提前拒绝，实际格式在checkVoice中以上传记录为准
+		if req.FileType != "" && !isVoiceFormat(req.FileType) {
+			return reject(frame_error_enum.INVALID_MESSAGE, "不支持的语音格式")
+		}
+	case message_type_enum.File:
+		if req.Url == "" {
+			return reject(frame_error_enum.INVALID_MESSAGE, "文件地址不能为空")
+		}
+		if len(req.Url) > maxUrlLength || utf8.RuneCountInString(req.FileName) > maxFileNameLength ||
+			len(req.FileType) > maxFileTypeLength || len(req.FileSize) > maxFileSizeLength {
+			return reject(frame_error_enum.CONTENT_TOO_LONG, "文件信息过长")
+		}
+	case message_type_enum.AudioOrVideo:
+		if req.AVdata == "" {
+			return reject(frame_error_enum.INVALID_MESSAGE, "通话数据不能为空")
+		}
+		if len(req.AVdata) > maxAVDataLength() {
+			return reject(frame_error_enum.CONTENT_TOO_LONG, "通话数据过长")
+		}
+	default:
+		return reject(frame_error_enum.INVALID_MESSAGE, "不支持的消息类型")
+	}
+	return nil
+}
+
+// checkContact 校验私聊双方是好友，且发送者没有拉黑对方
+// 对方拉黑发送者的情况在投递时按blockPolicy处理，见blocklist.go
+func checkContact(sendId string, receiveId string) *messageRejection {
+	var contact model.UserContact
+	if res := dao.GormDB.Where("user_id = ? AND contact_id = ?", sendId, receiveId).First(&contact); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return reject(frame_error_enum.NOT_CONTACT, "对方不是你的好友")
+		}
+		zlog.Error(res.Error.Error())
+		return reject(frame_error_enum.SEND_FAILED, "消息发送失败，请稍后重试")
+	}
+	switch contact.Status {
+	case contact_status_enum.NORMAL, contact_status_enum.SILENCE, contact_status_enum.BE_BLACK:
+		return nil
+	case contact_status_enum.BLACK:
+		return reject(frame_error_enum.BLOCKED, "你已将对方拉黑，无法发送消息")
+	default:
+		return reject(frame_error_enum.NOT_CONTACT, "对方不是你的好友")
+	}
+}
+
+// checkGroupMember 校验群聊可用且发送者是未被禁言的群成员
+// 禁言状态在投递前还会再校验一次，见group_mute.go
+func checkGroupMember(sendId string, groupId string) *messageRejection {
+	group, rejection := loadNormalGroup(groupId)
+	if rejection != nil {
+		return rejection
+	}
+	return checkGroupSpeak(group, sendId)
+}
+
+// loadNormalGroup 查询群聊并校验群聊可用
+func loadNormalGroup(groupId string) (*model.GroupInfo, *messageRejection) {
+	var group model.GroupInfo
+	if res := dao.GormDB.First(&group, "uuid = ?", groupId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return nil, reject(frame_error_enum.NOT_GROUP_MEMBER, "群聊不存在")
+		}
+		zlog.Error(res.Error.Error())
+		return nil, reject(frame_error_enum.SEND_FAILED, "消息发送失败，请稍后重试")
+	}
+	if group.Status != group_status_enum.NORMAL {
+		return nil, reject(frame_error_enum.GROUP_DISABLED, "群聊已被禁用或解散")
+	}
+	return &group, nil
+}
+
+// checkCallSignal 校验通话信令，发起和加入通话与发送消息的限制相同，被禁言的成员不能在群内发起或加入通话
+// 挂断、拒绝和离开信令只校验好友关系或群成员身份，不受禁言、拉黑和群聊状态限制，保证通话在任何情况下都能结束并释放线路
+func checkCallSignal(req *request.ChatMessageRequest) *messageRejection {
+	var avData request.AVData
+	if err := json.Unmarshal([]byte(req.AVdata), &avData); err != nil {
+		return reject(frame_error_enum.INVALID_MESSAGE, "通话数据格式错误")
+	}
+	ending := isCallEndSignal(avData.Type)
+
+	if req.ReceiveId[0] == 'U' {
+		rejection := checkContact(req.SendId, req.ReceiveId)
+		if rejection != nil && ending && rejection.code == frame_error_enum.BLOCKED {
+			return nil
+		}
+		return rejection
+	}
+	if !ending {
+		return checkGroupMember(req.SendId, req.ReceiveId)
+	}
+	_, rejection := loadGroupMember(req.ReceiveId, req.SendId)
+	return rejection
+}
+
+// checkFile 校验文件消息引用的服务端聊天文件是发送者自己上传的
+// 聊天文件只能通过签名下载地址访问，否则知道存储键的用户可以借发给自己的消息获取他人文件的下载地址
+// 外部链接不会被签发下载地址，不做校验
+func checkFile(req *request.ChatMessageRequest) *messageRejection {
+	if _, ok := chatFileKey(req.Url); !ok {
+		return nil
+	}
+	file, err := findUploadedFile(req.Url, req.SendId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return reject(frame_error_enum.SEND_FAILED, "消息发送失败，请稍后重试")
+	}
+	if file == nil {
+		return reject(frame_error_enum.INVALID_MESSAGE, "文件不存在，请重新上传")
+	}
+	return nil
+}
+
+// checkVoice 校验语音消息引用的是发送者自己上传的音频文件，格式和时长以上传时解析的结果为准
+func checkVoice(req *request.ChatMessageRequest) *messageRejection {
+	file, err := findUploadedFile(req.Url, req.SendId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return reject(frame_error_enum.SEND_FAILED, "消息发送失败，请稍后重试")
+	}
+	if file == nil {
+		return reject(frame_error_enum.INVALID_MESSAGE, "语音文件不存在，请重新上传")
+	}
+	format := strings.TrimPrefix(path.Ext(file.StorageName), ".")
+	if !isVoiceFormat(format) {
+		return reject(frame_error_enum.INVALID_MESSAGE, "不支持的语音格式")
+	}
+	if file.Duration <= 0 {
+		return reject(frame_error_enum.INVALID_MESSAGE, "无法识别语音时长")
+	}
+	maxDuration := config.GetConfig().ChatConfig.MaxVoiceDuration * time.Second
+	if time.Duration(file.Duration)*time.Millisecond > maxDuration {
+		return reject(frame_error_enum.CONTENT_TOO_LONG, "语音时长超过限制")
+	}
+	req.FileType = format
+	return nil
+}
+
+// isVoiceFormat 判断音频格式是否允许作为语音消息发送
+func isVoiceFormat(format string) bool {
+	for _, allowed := range config.GetConfig().ChatConfig.VoiceFormats {
+		if strings.EqualFold(format, allowed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,317 @@
+package gorm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gochat/internal/config"
+	"gochat/internal/dao"
+	"gochat/internal/dto/respond"
+	"gochat/internal/model"
+	myredis "gochat/internal/service/redis"
+	"gochat/internal/service/storage"
+	"gochat/pkg/constants"
+	"gochat/pkg/enum/file/file_category_enum"
+	"gochat/pkg/enum/group_info/group_status_enum"
+	"gochat/pkg/enum/user_info/user_status_enum"
+	"gochat/pkg/util/media"
+	"gochat/pkg/zlog"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type avatarService struct {
+}
+
+var AvatarService = new(avatarService)
+
+// avatarFormField 头像上传表单中图片字段的名称
+const avatarFormField = "avatar"
+
+// UpdateUserAvatar 更新用户头像
+// 表单字段：owner_id - 用户ID，avatar - 头像图片
+// 图片居中裁剪为正方形并缩放为avatarSizes中的各个边长，以用户ID命名保存到头像目录，
+// 在同一个事务中更新用户资料和以该用户为接收方的会话头像，然后清除相关缓存并删除旧头像
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - *respond.UpdateAvatarRespond: 新头像的访问地址
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示用户不存在或图片不符合要求
+func (a *avatarService) UpdateUserAvatar(c *gin.Context) (string, *respond.UpdateAvatarRespond, int) {
+	images, message, ret := readAvatarImages(c)
+	if ret != 0 {
+		return message, nil, ret
+	}
+
+	var user model.UserInfo
+	if res := dao.GormDB.Select("uuid", "avatar", "status").First(&user, "uuid = ?", c.Request.FormValue("owner_id")); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "用户不存在", nil, -2
+		}
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	if user.Status == user_status_enum.DISABLE {
+		return "账号已被禁用", nil, -2
+	}
+
+	rsp, err := storeAvatars(user.Uuid, images)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+
+	var oldAvatar string
+	if err := dao.GormDB.Transaction(func(tx *gorm.DB) error {
+		var current model.UserInfo
+		if res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("uuid", "avatar").
+			First(&current, "uuid = ?", user.Uuid); res.Error != nil {
+			return res.Error
+		}
+		oldAvatar = current.Avatar
+		if res := tx.Model(&model.UserInfo{}).Where("uuid = ?", user.Uuid).Update("avatar", rsp.Avatar); res.Error != nil {
+			return res.Error
+		}
+		// 私聊会话中保存了对方的头像，一并更新
+		return tx.Model(&model.Session{}).Where("receive_id = ?", user.Uuid).Update("avatar", rsp.Avatar).Error
+	}); err != nil {
+		zlog.Error(err.Error())
+		// 头像以内容哈希为版本，重新上传当前头像时新旧文件相同，不能删除
+		if rsp.Avatar != user.Avatar && rsp.Avatar != oldAvatar {
+			deleteAvatars(user.Uuid, rsp.Avatar)
+		}
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+
+	// 清除用户信息、好友的联系人列表、所在群的成员列表，以及与该用户的会话缓存
+	keys := []string{"user_info_" + user.Uuid, "session_*_" + user.Uuid + "_*"}
+	var contactIds []string
+	if res := dao.GormDB.Model(&model.UserContact{}).Where("user_id = ?", user.Uuid).Pluck("contact_id", &contactIds); res.Error != nil {
+		zlog.Error(res.Error.Error())
+	}
+	for _, contactId := range contactIds {
+		if strings.HasPrefix(contactId, "G") {
+			keys = append(keys, "group_memberlist_"+contactId)
+		} else {
+			keys = append(keys, "contact_user_list_"+contactId)
+		}
+	}
+	var sessionOwners []string
+	if res := dao.GormDB.Model(&model.Session{}).Where("receive_id = ?", user.Uuid).Pluck("send_id", &sessionOwners); res.Error != nil {
+		zlog.Error(res.Error.Error())
+	}
+	for _, ownerId := range sessionOwners {
+		keys = append(keys, "session_list_"+ownerId)
+	}
+	delAvatarCaches(keys)
+
+	if oldAvatar != rsp.Avatar {
+		deleteAvatars(user.Uuid, oldAvatar)
+	}
+	return "更新头像成功", rsp, 0
+}
+
+// UpdateGroupAvatar 更新群聊头像
+// 表单字段：owner_id - 操作者ID，group_id - 群聊ID，avatar - 头像图片
+// 只有群主和管理员可以更新，处理方式与用户头像相同，会话中保存的群聊头像一并更新
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - *respond.UpdateAvatarRespond: 新头像的访问地址
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示群聊不可用、没有权限或图片不符合要求
+func (a *avatarService) UpdateGroupAvatar(c *gin.Context) (string, *respond.UpdateAvatarRespond, int) {
+	images, message, ret := readAvatarImages(c)
+	if ret != 0 {
+		return message, nil, ret
+	}
+
+	group, message, ret := GroupInfoService.loadManagedGroup(c.Request.FormValue("group_id"), c.Request.FormValue("owner_id"))
+	if ret != 0 {
+		return message, nil, ret
+	}
+	if group.Status != group_status_enum.NORMAL {
+		return "群聊已被禁用或解散", nil, -2
+	}
+
+	rsp, err := storeAvatars(group.Uuid, images)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+
+	var oldAvatar string
+	if err := dao.GormDB.Transaction(func(tx *gorm.DB) error {
+		var current model.GroupInfo
+		if res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("uuid", "avatar").
+			First(&current, "uuid = ?", group.Uuid); res.Error != nil {
+			return res.Error
+		}
+		oldAvatar = current.Avatar
+		if res := tx.Model(&model.GroupInfo{}).Where("uuid = ?", group.Uuid).Update("avatar", rsp.Avatar); res.Error != nil {
+			return res.Error
+		}
+		return tx.Model(&model.Session{}).Where("receive_id = ?", group.Uuid).Update("avatar", rsp.Avatar).Error
+	}); err != nil {
+		zlog.Error(err.Error())
+		// 头像以内容哈希为版本，重新上传当前头像时新旧文件相同，不能删除
+		if rsp.Avatar != group.Avatar && rsp.Avatar != oldAvatar {
+			deleteAvatars(group.Uuid, rsp.Avatar)
+		}
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+
+	// 清除群聊信息、成员的群聊列表，以及该群聊的会话缓存
+	keys := []string{"group_info_" + group.Uuid, "session_*_" + group.Uuid + "_*"}
+	var members []string
+	if err := json.Unmarshal(group.Members, &members); err != nil {
+		zlog.Error(err.Error())
+	}
+	for _, memberId := range members {
+		keys = append(keys, "contact_mygroup_list_"+memberId, "my_joined_group_list_"+memberId)
+	}
+	var sessionOwners []string
+	if res := dao.GormDB.Model(&model.Session{}).Where("receive_id = ?", group.Uuid).Pluck("send_id", &sessionOwners); res.Error != nil {
+		zlog.Error(res.Error.Error())
+	}
+	for _, ownerId := range sessionOwners {
+		keys = append(keys, "group_session_list_"+ownerId)
+	}
+	delAvatarCaches(keys)
+
+	if oldAvatar != rsp.Avatar {
+		deleteAvatars(group.Uuid, oldAvatar)
+	}
+	return "更新群聊头像成功", rsp, 0
+}
+
+// readAvatarImages 读取表单中的头像图片，校验大小和类型后裁剪为avatarSizes中的各个边长
+// 返回的图片与avatarSizes一一对应
+func readAvatarImages(c *gin.Context) ([][]byte, string, int) {
+	sizes := config.GetConfig().UploadConfig.AvatarSizes
+	if len(sizes) == 0 {
+		zlog.Error("未配置uploadConfig.avatarSizes")
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	if max := maxUploadBytes(file_category_enum.AVATAR); max > 0 {
+		// 请求体还包含表单字段和分隔符，预留1MB
+		if c.Request.ContentLength > max+1024*1024 {
+			return nil, fmt.Sprintf("头像大小不能超过%dMB", uploadLimit(file_category_enum.AVATAR).MaxSize), -2
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max+1024*1024)
+	}
+
+	fileHeader, err := c.FormFile(avatarFormField)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, fmt.Sprintf("头像大小不能超过%dMB", uploadLimit(file_category_enum.AVATAR).MaxSize), -2
+		}
+		zlog.Info(err.Error())
+		return nil, "请选择头像图片", -2
+	}
+	if err := checkUploadSize(file_category_enum.AVATAR, fileHeader.Size); err != nil {
+		message, ret := uploadErrorRet(err)
+		return nil, message, ret
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		zlog.Error(err.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	defer file.Close()
+
+	// 根据文件内容识别类型，不信任客户端提供的Content-Type
+	sniff := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		zlog.Error(err.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	if err := checkMimeType(file_category_enum.AVATAR, http.DetectContentType(sniff[:n])); err != nil {
+		message, ret := uploadErrorRet(err)
+		return nil, message, ret
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		zlog.Error(err.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+
+	images, err := media.SquareImages(file, sizes)
+	if err != nil {
+		if errors.Is(err, media.ErrImageTooLarge) {
+			return nil, "图片尺寸过大", -2
+		}
+		zlog.Info(err.Error())
+		return nil, "无法识别的图片格式，请使用jpeg、png或gif图片", -2
+	}
+	return images, "", 0
+}
+
+// avatarKey 返回头像的对象key，格式为avatars/<ownerId>_<边长>_<版本>.jpg
+// 版本取自图片内容的哈希，更换头像后地址随之改变，不会命中客户端和CDN中的旧缓存
+func avatarKey(ownerId string, size int, version string) string {
+	return fmt.Sprintf("avatars/%s_%d_%s.jpg", ownerId, size, version)
+}
+
+// storeAvatars 将裁剪后的各个边长的头像保存到对象存储，images与avatarSizes一一对应
+func storeAvatars(ownerId string, images [][]byte) (*respond.UpdateAvatarRespond, error) {
+	sizes := config.GetConfig().UploadConfig.AvatarSizes
+	sum := sha256.Sum256(images[0])
+	version := hex.EncodeToString(sum[:8])
+
+	rsp := &respond.UpdateAvatarRespond{Sizes: make(map[int]string, len(sizes))}
+	for i, size := range sizes {
+		key := avatarKey(ownerId, size, version)
+		if err := storage.Default.Put(context.Background(), key, bytes.NewReader(images[i]), int64(len(images[i])), "image/jpeg"); err != nil {
+			return nil, err
+		}
+		rsp.Sizes[size] = storage.StaticURL(key)
+	}
+	rsp.Avatar = rsp.Sizes[sizes[0]]
+	return rsp, nil
+}
+
+// deleteAvatars 删除avatarUrl对应版本的所有边长的头像
+// 只删除以ownerId命名的头像，默认头像和旧版本上传接口保存的头像可能被共用，不会删除
+func deleteAvatars(ownerId string, avatarUrl string) {
+	key, ok := storage.KeyFromURL(avatarUrl)
+	if !ok || path.Dir(key) != "avatars" {
+		return
+	}
+	// 文件名为<ownerId>_<边长>_<版本>.jpg
+	parts := strings.Split(strings.TrimSuffix(path.Base(key), ".jpg"), "_")
+	if len(parts) != 3 || parts[0] != ownerId {
+		return
+	}
+	for _, size := range config.GetConfig().UploadConfig.AvatarSizes {
+		if err := storage.Default.Delete(context.Background(), avatarKey(ownerId, size, parts[2])); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+}
+
+// delAvatarCaches 清除头像变更影响的缓存，失败只记录日志
+// 确定的键一次DEL删除，只有含通配符的键才需要按模式扫描
+func delAvatarCaches(keys []string) {
+	exactKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !strings.Contains(key, "*") {
+			exactKeys = append(exactKeys, key)
+			continue
+		}
+		if err := myredis.DelKeysWithPattern(key); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+	if err := myredis.DelKeys(exactKeys...); err != nil {
+		zlog.Error(err.Error())
+	}
+}
@@ -0,0 +1,71 @@
+package gorm
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"gochat/internal/config"
+	"gochat/internal/dao"
+	"gochat/internal/dto/request"
+	"gochat/internal/dto/respond"
+	"gochat/internal/model"
+	"gochat/pkg/constants"
+	"gochat/pkg/enum/user_info/user_status_enum"
+	"gochat/pkg/zlog"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type callService struct {
+}
+
+var CallService = new(callService)
+
+// GetIceServers 获取发起通话所需的ICE服务器
+// 按TURN REST API的共享密钥方案签发临时凭证：用户名为"过期时间戳:用户ID"，
+// 密码为以共享密钥对用户名计算的HMAC-SHA1的base64编码，TURN服务器用同一个密钥校验，不需要保存凭证
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - respond.GetIceServersRespond: STUN和TURN服务器列表，以及TURN凭证的有效期
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示用户不存在或已被禁用
+func (c *callService) GetIceServers(req request.GetIceServersRequest) (string, respond.GetIceServersRespond, int) {
+	var user model.UserInfo
+	if res := dao.GormDB.Select("uuid", "status").First(&user, "uuid = ?", req.OwnerId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "用户不存在", respond.GetIceServersRespond{}, -2
+		}
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, respond.GetIceServersRespond{}, -1
+	}
+	if user.Status == user_status_enum.DISABLE {
+		return "账号已被禁用", respond.GetIceServersRespond{}, -2
+	}
+
+	iceConfig := config.GetConfig().IceConfig
+	rsp := respond.GetIceServersRespond{IceServers: []respond.IceServerRespond{}}
+	if len(iceConfig.StunUrls) > 0 {
+		rsp.IceServers = append(rsp.IceServers, respond.IceServerRespond{Urls: iceConfig.StunUrls})
+	}
+	// 没有配置共享密钥时不能签发凭证，客户端只能使用STUN
+	if len(iceConfig.TurnUrls) > 0 && iceConfig.TurnSecret != "" {
+		ttl := iceConfig.TurnTTL * time.Hour
+		username := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10) + ":" + user.Uuid
+		rsp.IceServers = append(rsp.IceServers, respond.IceServerRespond{
+			Urls:       iceConfig.TurnUrls,
+			Username:   username,
+			Credential: turnCredential(iceConfig.TurnSecret, username),
+		})
+		rsp.Ttl = int64(ttl / time.Second)
+	}
+	return "获取成功", rsp, 0
+}
+
+// turnCredential 计算TURN临时凭证的密码
+func turnCredential(secret string, username string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
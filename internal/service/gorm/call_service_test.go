@@ -0,0 +1,30 @@
+package gorm
+
+import "testing"
+
+func TestTurnCredential(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		username string
+		want     string
+	}{
+		// RFC 2202 HMAC-SHA1 测试用例2，摘要为effcdf6ae5eb2fa2d27416d5f184df9c259a7c79
+		{name: "rfc 2202", secret: "Jefe", username: "what do ya want for nothing?", want: "7/zfauXrL6LSdBbV8YTfnCWafHk="},
+		{name: "turn username", secret: "north", username: "1700000000:U0000000001", want: "a9cM7BtwmLVuHJXEqVZGyLJTErg="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := turnCredential(tt.secret, tt.username); got != tt.want {
+				t.Errorf("turnCredential() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTurnCredentialDependsOnSecret(t *testing.T) {
+	const username = "1700000000:U0000000001"
+	if turnCredential("north", username) == turnCredential("south", username) {
+		t.Error("turnCredential() returned the same credential for different secrets")
+	}
+}
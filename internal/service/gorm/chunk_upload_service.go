@@ -0,0 +1,365 @@
+package gorm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gochat/internal/config"
+	"gochat/internal/dao"
+	"gochat/internal/dto/request"
+	"gochat/internal/dto/respond"
+	"gochat/internal/model"
+	"gochat/internal/service/storage"
+	"gochat/pkg/constants"
+	"gochat/pkg/enum/file/file_category_enum"
+	"gochat/pkg/enum/file/upload_status_enum"
+	"gochat/pkg/util/random"
+	"gochat/pkg/zlog"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type chunkUploadService struct {
+}
+
+var ChunkUploadService = new(chunkUploadService)
+
+// 未配置或配置为0时使用的默认值
+const (
+	defaultUploadChunkSize = 4096 // 分片大小，单位KB
+	defaultUploadExpire    = 24   // 上传会话的有效期，单位小时
+)
+
+// uploadChunkSize 分片上传的分片大小，单位字节
+func uploadChunkSize() int64 {
+	if size := config.GetConfig().UploadConfig.ChunkSize; size > 0 {
+		return size * 1024
+	}
+	return defaultUploadChunkSize * 1024
+}
+
+// uploadExpire 分片上传会话的有效期
+func uploadExpire() time.Duration {
+	if expire := config.GetConfig().UploadConfig.Expire; expire > 0 {
+		return expire * time.Hour
+	}
+	return defaultUploadExpire * time.Hour
+}
+
+// InitUpload 创建分片上传会话
+// 同一用户上传大小和sha256相同、且未过期的文件时返回已有的会话，客户端断线后可以据此续传
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - respond.UploadSessionRespond: 上传会话，包含分片大小和已收到的分片
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示参数错误或超过大小限制
+func (u *chunkUploadService) InitUpload(req request.InitUploadRequest) (string, respond.UploadSessionRespond, int) {
+	if req.Category != file_category_enum.FILE && req.Category != file_category_enum.AVATAR {
+		return "未知的文件用途", respond.UploadSessionRespond{}, -2
+	}
+	if req.FileSize <= 0 {
+		return "文件大小必须大于0", respond.UploadSessionRespond{}, -2
+	}
+	if !isSha256Hex(req.Sha256) {
+		return "文件sha256格式错误", respond.UploadSessionRespond{}, -2
+	}
+	if err := checkUploadSize(req.Category, req.FileSize); err != nil {
+		message, ret := uploadErrorRet(err)
+		return message, respond.UploadSessionRespond{}, ret
+	}
+	sha := strings.ToLower(req.Sha256)
+
+	// 查找可以续传的会话
+	var session model.UploadSession
+	res := dao.GormDB.Where("owner_id = ? AND sha256 = ? AND total_size = ? AND category = ? AND status = ? AND expire_at > ?",
+		req.OwnerId, sha, req.FileSize, req.Category, upload_status_enum.UPLOADING, time.Now()).
+		Order("created_at DESC").First(&session)
+	if res.Error == nil {
+		return u.sessionRespond(&session)
+	}
+	if !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, respond.UploadSessionRespond{}, -1
+	}
+
+	chunkSize := uploadChunkSize()
+	session = model.UploadSession{
+		Uuid:        fmt.Sprintf("P%s", random.GetNowAndLenRandomString(11)), // 生成上传会话唯一标识，以'P'开头
+		OwnerId:     req.OwnerId,
+		Category:    req.Category,
+		FileName:    sanitizeFileName(req.FileName),
+		TotalSize:   req.FileSize,
+		ChunkSize:   chunkSize,
+		TotalChunks: int((req.FileSize + chunkSize - 1) / chunkSize),
+		Sha256:      sha,
+		Status:      upload_status_enum.UPLOADING,
+		ExpireAt:    time.Now().Add(uploadExpire()),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if res := dao.GormDB.Create(&session); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, respond.UploadSessionRespond{}, -1
+	}
+	return u.sessionRespond(&session)
+}
+
+// UploadPart 上传一个分片
+// 表单字段：owner_id、upload_id、part_index（从0开始）、sha256（分片的sha256），文件字段part
+// 除最后一个分片外，分片大小必须等于会话的分片大小；重复上传同一分片会覆盖之前的内容
+func (u *chunkUploadService) UploadPart(c *gin.Context) (string, respond.UploadSessionRespond, int) {
+	chunkSize := uploadChunkSize()
+	// 请求体还包含表单字段和分隔符，预留1MB
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, chunkSize+1024*1024)
+	if err := c.Request.ParseMultipartForm(constants.FILE_MAX_SIZE); err != nil {
+		zlog.Error(err.Error())
+		return "分片解析失败", respond.UploadSessionRespond{}, -2
+	}
+
+	session, message, ret := u.loadUploadingSession(c.Request.FormValue("upload_id"), c.Request.FormValue("owner_id"))
+	if ret != 0 {
+		return message, respond.UploadSessionRespond{}, ret
+	}
+	partIndex, err := strconv.Atoi(c.Request.FormValue("part_index"))
+	if err != nil || partIndex < 0 || partIndex >= session.TotalChunks {
+		return "分片序号错误", respond.UploadSessionRespond{}, -2
+	}
+	partSha := strings.ToLower(c.Request.FormValue("sha256"))
+	if !isSha256Hex(partSha) {
+		return "分片sha256格式错误", respond.UploadSessionRespond{}, -2
+	}
+
+	file, _, err := c.Request.FormFile("part")
+	if err != nil {
+		return "缺少分片内容", respond.UploadSessionRespond{}, -2
+	}
+	defer file.Close()
+
+	expectedSize := session.ChunkSize
+	if partIndex == session.TotalChunks-1 {
+		expectedSize = session.TotalSize - session.ChunkSize*int64(session.TotalChunks-1)
+	}
+	data, err := io.ReadAll(io.LimitReader(file, expectedSize+1))
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, respond.UploadSessionRespond{}, -1
+	}
+	if int64(len(data)) != expectedSize {
+		return fmt.Sprintf("分片大小错误，应为%d字节", expectedSize), respond.UploadSessionRespond{}, -2
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != partSha {
+		return "分片校验失败，请重新上传该分片", respond.UploadSessionRespond{}, -2
+	}
+
+	if err := storage.Default.Put(context.Background(), partKey(session.Uuid, partIndex), bytes.NewReader(data), expectedSize, "application/octet-stream"); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, respond.UploadSessionRespond{}, -1
+	}
+	part := model.UploadPart{
+		UploadId:  session.Uuid,
+		PartIndex: partIndex,
+		Size:      expectedSize,
+		Sha256:    partSha,
+		CreatedAt: time.Now(),
+	}
+	if res := dao.GormDB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "upload_id"}, {Name: "part_index"}},
+		DoUpdates: clause.AssignmentColumns([]string{"size", "sha256", "created_at"}),
+	}).Create(&part); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, respond.UploadSessionRespond{}, -1
+	}
+	return u.sessionRespond(session)
+}
+
+// GetUploadStatus 查询上传会话的状态和已收到的分片，用于断线后续传
+func (u *chunkUploadService) GetUploadStatus(req request.UploadSessionRequest) (string, respond.UploadSessionRespond, int) {
+	session, message, ret := u.loadUploadingSession(req.UploadId, req.OwnerId)
+	if ret != 0 {
+		return message, respond.UploadSessionRespond{}, ret
+	}
+	return u.sessionRespond(session)
+}
+
+// CompleteUpload 合并所有分片，校验完整文件的sha256后保存到对象存储
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - respond.UploadFileRespond: 文件的访问地址和元数据，与普通上传相同
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示分片不完整、校验失败或不满足上传限制
+func (u *chunkUploadService) CompleteUpload(req request.UploadSessionRequest) (string, respond.UploadFileRespond, int) {
+	session, message, ret := u.loadUploadingSession(req.UploadId, req.OwnerId)
+	if ret != 0 {
+		return message, respond.UploadFileRespond{}, ret
+	}
+	var received int64
+	if res := dao.GormDB.Model(&model.UploadPart{}).Where("upload_id = ?", session.Uuid).Count(&received); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, respond.UploadFileRespond{}, -1
+	}
+	if int(received) != session.TotalChunks {
+		return fmt.Sprintf("分片不完整，已收到%d/%d", received, session.TotalChunks), respond.UploadFileRespond{}, -2
+	}
+
+	// 以条件更新占有会话，同一会话并发完成时只有一个请求会合并分片
+	res := dao.GormDB.Model(&model.UploadSession{}).
+		Where("id = ? AND status = ?", session.Id, upload_status_enum.UPLOADING).
+		Updates(map[string]interface{}{"status": upload_status_enum.COMPLETED, "updated_at": time.Now()})
+	if res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, respond.UploadFileRespond{}, -1
+	}
+	if res.RowsAffected == 0 {
+		return "上传已完成或已取消", respond.UploadFileRespond{}, -2
+	}
+
+	reader := &partReader{uploadId: session.Uuid, total: session.TotalChunks}
+	defer reader.Close()
+	rsp, err := storeFile(reader, session.FileName, session.Category, session.OwnerId, session.Sha256)
+	if err != nil {
+		var rejected *uploadRejectedError
+		if errors.As(err, &rejected) {
+			// 分片都校验过，完整文件仍不满足要求时只能重新上传
+			u.discard(session, upload_status_enum.ABORTED)
+		} else if res := dao.GormDB.Model(&model.UploadSession{}).Where("id = ?", session.Id).
+			Update("status", upload_status_enum.UPLOADING); res.Error != nil {
+			// 系统错误时恢复会话状态，客户端可以重试完成
+			zlog.Error(res.Error.Error())
+		}
+		message, ret := uploadErrorRet(err)
+		return message, respond.UploadFileRespond{}, ret
+	}
+	u.discard(session, upload_status_enum.COMPLETED)
+	return "上传成功", rsp, 0
+}
+
+// AbortUpload 取消上传，清理已上传的分片
+func (u *chunkUploadService) AbortUpload(req request.UploadSessionRequest) (string, int) {
+	session, message, ret := u.loadUploadingSession(req.UploadId, req.OwnerId)
+	if ret != 0 {
+		return message, ret
+	}
+	u.discard(session, upload_status_enum.ABORTED)
+	return "已取消上传", 0
+}
+
+// loadUploadingSession 查询上传者的进行中的上传会话，过期的会话会被清理
+func (u *chunkUploadService) loadUploadingSession(uploadId string, ownerId string) (*model.UploadSession, string, int) {
+	var session model.UploadSession
+	if res := dao.GormDB.First(&session, "uuid = ?", uploadId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return nil, "上传会话不存在", -2
+		}
+		zlog.Error(res.Error.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	if session.OwnerId != ownerId {
+		return nil, "上传会话不存在", -2
+	}
+	if session.Status != upload_status_enum.UPLOADING {
+		return nil, "上传已完成或已取消", -2
+	}
+	if time.Now().After(session.ExpireAt) {
+		u.discard(&session, upload_status_enum.ABORTED)
+		return nil, "上传已过期，请重新上传", -2
+	}
+	return &session, "", 0
+}
+
+// sessionRespond 构建上传会话的响应，包含已收到的分片序号
+func (u *chunkUploadService) sessionRespond(session *model.UploadSession) (string, respond.UploadSessionRespond, int) {
+	receivedParts := make([]int, 0, session.TotalChunks)
+	if res := dao.GormDB.Model(&model.UploadPart{}).Where("upload_id = ?", session.Uuid).
+		Order("part_index").Pluck("part_index", &receivedParts); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, respond.UploadSessionRespond{}, -1
+	}
+	return "获取成功", respond.UploadSessionRespond{
+		UploadId:      session.Uuid,
+		ChunkSize:     session.ChunkSize,
+		TotalChunks:   session.TotalChunks,
+		ReceivedParts: receivedParts,
+		ExpireAt:      session.ExpireAt.Format("2006-01-02 15:04:05"),
+	}, 0
+}
+
+// discard 删除会话的所有分片并更新会话状态，清理失败只记录日志
+func (u *chunkUploadService) discard(session *model.UploadSession, status int8) {
+	ctx := context.Background()
+	for i := 0; i < session.TotalChunks; i++ {
+		if err := storage.Default.Delete(ctx, partKey(session.Uuid, i)); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+	if res := dao.GormDB.Where("upload_id = ?", session.Uuid).Delete(&model.UploadPart{}); res.Error != nil {
+		zlog.Error(res.Error.Error())
+	}
+	if res := dao.GormDB.Model(&model.UploadSession{}).Where("id = ?", session.Id).
+		Updates(map[string]interface{}{"status": status, "updated_at": time.Now()}); res.Error != nil {
+		zlog.Error(res.Error.Error())
+	}
+}
+
+// partKey 返回分片在对象存储中的key
+func partKey(uploadId string, partIndex int) string {
+	return fmt.Sprintf("parts/%s_%d", uploadId, partIndex)
+}
+
+// isSha256Hex 判断字符串是否是十六进制表示的sha256
+func isSha256Hex(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// partReader 按顺序读取会话的所有分片，读到一个分片末尾时才打开下一个分片
+type partReader struct {
+	uploadId string
+	total    int
+	next     int
+	current  io.ReadCloser
+}
+
+func (p *partReader) Read(buf []byte) (int, error) {
+	for {
+		if p.current == nil {
+			if p.next >= p.total {
+				return 0, io.EOF
+			}
+			part, err := storage.Default.Open(context.Background(), partKey(p.uploadId, p.next))
+			if err != nil {
+				return 0, err
+			}
+			p.current = part
+			p.next++
+		}
+		n, err := p.current.Read(buf)
+		if err == io.EOF {
+			p.current.Close()
+			p.current = nil
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, err
+	}
+}
+
+func (p *partReader) Close() error {
+	if p.current != nil {
+		return p.current.Close()
+	}
+	return nil
+}
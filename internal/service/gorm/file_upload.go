@@ -0,0 +1,357 @@
+package gorm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gochat/internal/config"
+	"gochat/internal/dao"
+	"gochat/internal/dto/respond"
+	"gochat/internal/model"
+	"gochat/internal/service/storage"
+	"gochat/pkg/constants"
+	"gochat/pkg/enum/file/file_category_enum"
+	"gochat/pkg/util/media"
+	"gochat/pkg/util/random"
+	"gochat/pkg/zlog"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxOriginalNameLength = 50 // 清洗后原始文件名的最大字符数，与消息表的文件名长度一致
+	maxExtLength          = 10 // 扩展名（不含点）的最大长度，与消息表的文件类型长度一致
+	sniffLength           = 512
+	thumbnailMaxSide      = 320 // 缩略图长边的最大像素数
+)
+
+// uploadRejectedError 文件不满足上传限制或校验失败，错误信息可以直接返回给客户端
+type uploadRejectedError struct {
+	message string
+}
+
+func (e *uploadRejectedError) Error() string {
+	return e.message
+}
+
+// uploadErrorRet 将保存文件的错误转换为返回给客户端的提示和状态码
+func uploadErrorRet(err error) (string, int) {
+	var rejected *uploadRejectedError
+	if errors.As(err, &rejected) {
+		return rejected.message, -2
+	}
+	zlog.Error(err.Error())
+	return constants.SYSTEM_ERROR, -1
+}
+
+// categoryDir 返回文件用途对应的对象存储目录
+func categoryDir(category int8) string {
+	if category == file_category_enum.AVATAR {
+		return "avatars"
+	}
+	return "files"
+}
+
+// uploadLimit 返回文件用途对应的上传限制
+func uploadLimit(category int8) config.UploadLimit {
+	if category == file_category_enum.AVATAR {
+		return config.GetConfig().UploadConfig.Avatar
+	}
+	return config.GetConfig().UploadConfig.File
+}
+
+// maxUploadBytes 返回文件用途允许的最大字节数，0表示不限
+func maxUploadBytes(category int8) int64 {
+	return uploadLimit(category).MaxSize * 1024 * 1024
+}
+
+// checkUploadSize 校验文件大小是否超过限制
+func checkUploadSize(category int8, size int64) error {
+	if max := maxUploadBytes(category); max > 0 && size > max {
+		return &uploadRejectedError{message: fmt.Sprintf("文件大小不能超过%dMB", uploadLimit(category).MaxSize)}
+	}
+	return nil
+}
+
+// checkMimeType 校验根据文件内容识别的类型是否在允许的范围内
+func checkMimeType(category int8, contentType string) error {
+	allowed := uploadLimit(category).MimeTypes
+	if len(allowed) == 0 {
+		return nil
+	}
+	// 去掉charset等参数
+	mimeType := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	for _, pattern := range allowed {
+		if pattern == mimeType || (strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(pattern, "*"))) {
+			return nil
+		}
+	}
+	return &uploadRejectedError{message: "不支持的文件类型：" + mimeType}
+}
+
+// storeUpload 保存一个通过表单上传的文件
+func storeUpload(fileHeader *multipart.FileHeader, category int8, uploaderId string) (respond.UploadFileRespond, error) {
+	if err := checkUploadSize(category, fileHeader.Size); err != nil {
+		return respond.UploadFileRespond{}, err
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return respond.UploadFileRespond{}, err
+	}
+	defer file.Close()
+	return storeFile(file, fileHeader.Filename, category, uploaderId, "")
+}
+
+// storeFile 保存一个上传的文件
+// 文件以内容的sha256加扩展名命名保存到对象存储中文件用途对应的目录下，内容相同的文件只保存一份；每次上传都会记录一条上传记录，保留清洗后的原始文件名
+// 参数:
+//   - reader: 文件内容
+//   - originalName: 客户端提供的原始文件名
+//   - category: 文件用途，见file_category_enum
+//   - uploaderId: 上传者ID，可以为空
+//   - expectedSha256: 客户端声明的sha256，不为空时与实际内容比对
+func storeFile(reader io.Reader, originalName string, category int8, uploaderId string, expectedSha256 string) (respond.UploadFileRespond, error) {
+	// 先写入临时文件并同时计算哈希，得到哈希后才能确定对象名，避免把整个文件读入内存
+	tmp, err := os.CreateTemp("", "gochat-upload-*")
+	if err != nil {
+		return respond.UploadFileRespond{}, err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	// 多读一个字节用于判断是否超过大小限制
+	if max := maxUploadBytes(category); max > 0 {
+		reader = io.LimitReader(reader, max+1)
+	}
+	hasher := sha256.New()
+	sniff := &sniffWriter{}
+	size, err := io.Copy(io.MultiWriter(tmp, hasher, sniff), reader)
+	if err != nil {
+		return respond.UploadFileRespond{}, err
+	}
+	if err := checkUploadSize(category, size); err != nil {
+		return respond.UploadFileRespond{}, err
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if expectedSha256 != "" && !strings.EqualFold(expectedSha256, hash) {
+		return respond.UploadFileRespond{}, &uploadRejectedError{message: "文件校验失败，请重新上传"}
+	}
+	contentType := http.DetectContentType(sniff.buf)
+	if err := checkMimeType(category, contentType); err != nil {
+		return respond.UploadFileRespond{}, err
+	}
+
+	ext := sanitizeExt(originalName)
+	storageName := hash
+	if ext != "" {
+		storageName += "." + ext
+	}
+	key := categoryDir(category) + "/" + storageName
+
+	ctx := context.Background()
+	duplicate, err := storage.Default.Exists(ctx, key)
+	if err != nil {
+		return respond.UploadFileRespond{}, err
+	}
+	if !duplicate {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return respond.UploadFileRespond{}, err
+		}
+		if err := storage.Default.Put(ctx, key, tmp, size, contentType); err != nil {
+			return respond.UploadFileRespond{}, err
+		}
+	}
+
+	// 聊天文件提取图片尺寸、缩略图和音频时长，解析失败不影响上传
+	var info mediaInfo
+	if category == file_category_enum.FILE {
+		info = extractMedia(ctx, tmp, contentType, ext, hash)
+	}
+
+	record := model.UploadedFile{
+		Uuid:         fmt.Sprintf("F%s", random.GetNowAndLenRandomString(11)), // 生成上传记录唯一标识，以'F'开头
+		Hash:         hash,
+		Category:     category,
+		StorageName:  storageName,
+		OriginalName: sanitizeFileName(originalName),
+		ContentType:  contentType,
+		Size:         size,
+		Width:        info.width,
+		Height:       info.height,
+		Duration:     info.duration,
+		ThumbnailUrl: info.thumbnailUrl,
+		UploaderId:   uploaderId,
+		CreatedAt:    time.Now(),
+	}
+	// 发送文件消息时以上传记录证明发送者上传过该文件，记录写入失败时上传视为失败，文件已保存，重新上传时不会重复写入
+	if res := dao.GormDB.Create(&record); res.Error != nil {
+		return respond.UploadFileRespond{}, res.Error
+	}
+
+	// 聊天文件只能通过签名下载地址访问，为上传者签发一个用于立即预览
+	downloadUrl := storage.SignDownloadURL(key, uploaderId)
+	if storage.IsPublic(key) {
+		if downloadUrl, err = storage.Default.DownloadURL(ctx, key); err != nil {
+			// 下载地址只是便于客户端立即预览，获取失败时客户端仍可以通过固定地址访问
+			zlog.Error(err.Error())
+			downloadUrl = storage.StaticURL(key)
+		}
+	}
+	return respond.UploadFileRespond{
+		FileId:       record.Uuid,
+		Url:          storage.StaticURL(key),
+		DownloadUrl:  downloadUrl,
+		FileName:     record.OriginalName,
+		FileSize:     formatFileSize(size),
+		FileType:     ext,
+		Hash:         hash,
+		Width:        info.width,
+		Height:       info.height,
+		Duration:     info.duration,
+		ThumbnailUrl: storage.SignStaticURL(info.thumbnailUrl, uploaderId),
+		Duplicate:    duplicate,
+	}, nil
+}
+
+// mediaInfo 上传文件的媒体信息
+type mediaInfo struct {
+	width        int
+	height       int
+	duration     int    // 音频时长，单位毫秒
+	thumbnailUrl string // 缩略图的固定访问路径
+}
+
+// extractMedia 从已保存的临时文件中提取媒体信息，图片生成缩略图保存到对象存储的thumbs目录下
+func extractMedia(ctx context.Context, file *os.File, contentType string, ext string, hash string) mediaInfo {
+	var info mediaInfo
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		zlog.Error(err.Error())
+		return info
+	}
+
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		width, height, err := media.ImageSize(file)
+		if err != nil {
+			// 标准库不支持的图片格式（如webp）没有尺寸和缩略图
+			return info
+		}
+		info.width, info.height = width, height
+
+		key := "thumbs/" + hash + ".jpg"
+		exists, err := storage.Default.Exists(ctx, key)
+		if err != nil {
+			zlog.Error(err.Error())
+			return info
+		}
+		if !exists {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				zlog.Error(err.Error())
+				return info
+			}
+			thumbnail, err := media.Thumbnail(file, thumbnailMaxSide)
+			if err != nil {
+				zlog.Warn("生成缩略图失败：" + err.Error())
+				return info
+			}
+			if err := storage.Default.Put(ctx, key, bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/jpeg"); err != nil {
+				zlog.Error(err.Error())
+				return info
+			}
+		}
+		info.thumbnailUrl = storage.StaticURL(key)
+	case strings.HasPrefix(contentType, "audio/") || ext == "mp3" || ext == "wav":
+		stat, err := file.Stat()
+		if err != nil {
+			zlog.Error(err.Error())
+			return info
+		}
+		duration, err := media.AudioDuration(file, stat.Size(), ext)
+		if err != nil {
+			return info
+		}
+		info.duration = int(duration.Milliseconds())
+	}
+	return info
+}
+
+// sniffWriter 保留写入内容的前512字节，用于识别文件类型
+type sniffWriter struct {
+	buf []byte
+}
+
+func (s *sniffWriter) Write(p []byte) (int, error) {
+	if remain := sniffLength - len(s.buf); remain > 0 {
+		if len(p) < remain {
+			remain = len(p)
+		}
+		s.buf = append(s.buf, p[:remain]...)
+	}
+	return len(p), nil
+}
+
+// sanitizeFileName 清洗客户端提供的文件名，只保留最后一级名称，去掉控制字符和路径分隔符，并限制长度
+// 清洗后的文件名只作为展示用的元数据，不会用于拼接保存路径
+func sanitizeFileName(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = filepath.Base(name)
+	name = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || unicode.IsControl(r) || r == '/' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	if utf8.RuneCountInString(name) > maxOriginalNameLength {
+		// 保留扩展名，截断主文件名
+		ext := filepath.Ext(name)
+		if utf8.RuneCountInString(ext) >= maxOriginalNameLength {
+			ext = ""
+		}
+		runes := []rune(strings.TrimSuffix(name, ext))
+		name = string(runes[:maxOriginalNameLength-utf8.RuneCountInString(ext)]) + ext
+	}
+	return name
+}
+
+// sanitizeExt 从文件名中提取扩展名，转为小写，只允许字母和数字，不合法时返回空字符串
+func sanitizeExt(name string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(sanitizeFileName(name)), "."))
+	if ext == "" || len(ext) > maxExtLength {
+		return ""
+	}
+	for _, r := range ext {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return ""
+		}
+	}
+	return ext
+}
+
+// formatFileSize 将字节数格式化为便于展示的大小
+func formatFileSize(size int64) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%dB", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1fKB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%.1fMB", float64(size)/1024/1024)
+	}
+}
@@ -1,6 +1,7 @@
 package gorm
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,7 +13,10 @@ import (
 	"gochat/pkg/constants"
 	"gochat/pkg/enum/contact/contact_status_enum"
 	"gochat/pkg/enum/contact/contact_type_enum"
+	"gochat/pkg/enum/contact_apply/contact_apply_status_enum"
+	"gochat/pkg/enum/group_info/add_mode_enum"
 	"gochat/pkg/enum/group_info/group_status_enum"
+	"gochat/pkg/enum/notification/notification_type_enum"
 	"gochat/pkg/util/random"
 	"gochat/pkg/zlog"
 	"log"
@@ -205,7 +209,7 @@ func (g *groupInfoService) CheckGroupAddMode(groupId string) (string, int8, int)
 }
 
 // EnterGroupDirectly 直接进群
-// 让指定用户直接加入群聊，适用于允许任何人加入的群聊
+// 让指定用户直接加入群聊，只适用于加群方式为直接加入的群聊，需要审核的群聊须发送加群申请或通过邀请加入
 // 参数: ownerId - 群聊UUID
 // 参数: contactId - 要加入群聊的用户UUID
 // 返回值:
@@ -215,11 +219,62 @@ func (g *groupInfoService) EnterGroupDirectly(ownerId, contactId string) (string
 	// 查询群聊信息
 	var group model.GroupInfo
 	if res := dao.GormDB.First(&group, "uuid = ?", ownerId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "群聊不存在", -2
+		}
 		// 数据库查询失败，记录错误日志并返回系统错误
 		zlog.Error(res.Error.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
 
+	// 需要审核的群聊不能直接加入
+	if group.AddMode != add_mode_enum.DIRECT {
+		return "该群聊需要审核，请发送加群申请", -2
+	}
+	if message, ret := checkCanJoinGroup(&group, contactId); ret != 0 {
+		return message, ret
+	}
+	return addGroupMember(&group, contactId)
+}
+
+// checkCanJoinGroup 校验用户能否加入群聊：群聊状态正常、用户不在群内、未被禁止申请加入
+func checkCanJoinGroup(group *model.GroupInfo, userId string) (string, int) {
+	if group.Status != group_status_enum.NORMAL {
+		return "群聊已被禁用", -2
+	}
+	isMember, err := isGroupMember(group.Uuid, userId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if isMember {
+		return "已是该群成员", -2
+	}
+	var blackCount int64
+	if res := dao.GormDB.Model(&model.ContactApply{}).
+		Where("contact_id = ? AND user_id = ? AND status = ?", group.Uuid, userId, contact_apply_status_enum.BLACK).
+		Count(&blackCount); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if blackCount > 0 {
+		return "已被禁止加入该群聊", -2
+	}
+	return "", 0
+}
+
+// isGroupMember 判断用户是否是群成员，退群和被移出群聊的成员关系记录已被软删除，不会被统计
+func isGroupMember(groupId string, userId string) (bool, error) {
+	var count int64
+	if res := dao.GormDB.Model(&model.UserContact{}).Where("user_id = ? AND contact_id = ?", userId, groupId).Count(&count); res.Error != nil {
+		return false, res.Error
+	}
+	return count > 0, nil
+}
+
+// addGroupMember 将用户加入群聊，更新群成员列表并创建用户与群聊的联系人记录
+// 调用方负责校验用户能否加入
+func addGroupMember(group *model.GroupInfo, userId string) (string, int) {
 	// 反序列化群成员列表
 	var members []string
 	if err := json.Unmarshal(group.Members, &members); err != nil {
@@ -229,7 +284,7 @@ func (g *groupInfoService) EnterGroupDirectly(ownerId, contactId string) (string
 	}
 
 	// 将新用户添加到群成员列表
-	members = append(members, contactId)
+	members = append(members, userId)
 
 	// 重新序列化群成员列表
 	if data, err := json.Marshal(members); err != nil {
@@ -241,11 +296,11 @@ func (g *groupInfoService) EnterGroupDirectly(ownerId, contactId string) (string
 		group.Members = data
 	}
 
-	// 增加群成员计数
-	group.MemberCnt += 1
+	// 更新群成员计数
+	group.MemberCnt = len(members)
 
 	// 保存更新后的群聊信息
-	if res := dao.GormDB.Save(&group); res.Error != nil {
+	if res := dao.GormDB.Save(group); res.Error != nil {
 		// 数据库保存失败，记录错误日志并返回系统错误
 		zlog.Error(res.Error.Error())
 		return constants.SYSTEM_ERROR, -1
@@ -253,8 +308,8 @@ func (g *groupInfoService) EnterGroupDirectly(ownerId, contactId string) (string
 
 	// 创建用户与群聊的联系人记录，使群聊出现在用户的群聊列表中
 	newContact := model.UserContact{
-		UserId:      contactId,                  // 用户ID
-		ContactId:   ownerId,                    // 联系人ID（群聊UUID）
+		UserId:      userId,                     // 用户ID
+		ContactId:   group.Uuid,                 // 联系人ID（群聊UUID）
 		ContactType: contact_type_enum.GROUP,    // 联系人类型为群聊
 		Status:      contact_status_enum.NORMAL, // 状态为正常
 		CreatedAt:   time.Now(),                 // 创建时间
@@ -268,30 +323,17 @@ func (g *groupInfoService) EnterGroupDirectly(ownerId, contactId string) (string
 		return constants.SYSTEM_ERROR, -1
 	}
 
-	// 清除相关缓存，确保群聊成员列表和用户群聊列表及时更新
-	// TODO: 以下缓存清除逻辑可根据实际需要启用
-	if err := myredis.DelKeysWithPattern("group_info_" + contactId); err != nil {
-		zlog.Error(err.Error())
-	}
-	if err := myredis.DelKeysWithPattern("groupmember_list_" + contactId); err != nil {
-		zlog.Error(err.Error())
-	}
-
-	// 清除群聊会话列表缓存
-	if err := myredis.DelKeysWithPattern("group_session_list_" + ownerId); err != nil {
-		// 缓存清除失败，记录错误日志（不影响主要流程）
-		zlog.Error(err.Error())
-	}
-
-	// 清除用户加入的群聊列表缓存
-	if err := myredis.DelKeysWithPattern("my_joined_group_list_" + ownerId); err != nil {
-		// 缓存清除失败，记录错误日志（不影响主要流程）
-		zlog.Error(err.Error())
-	}
-
-	// TODO: 如需要，可取消下面的注释来清除会话缓存
-	if err := myredis.DelKeysWithPattern("session_" + ownerId + "_" + contactId); err != nil {
-		zlog.Error(err.Error())
+	// 清除相关缓存，确保群聊信息、群成员列表和用户群聊列表及时更新
+	for _, key := range []string{
+		"group_info_" + group.Uuid,
+		"group_memberlist_" + group.Uuid,
+		"my_joined_group_list_" + userId,
+		"group_session_list_" + userId,
+	} {
+		if err := myredis.DelKeysWithPattern(key); err != nil {
+			// 缓存清除失败，记录错误日志（不影响主要流程）
+			zlog.Error(err.Error())
+		}
 	}
 
 	// 返回进群成功的消息
@@ -344,6 +386,12 @@ func (g *groupInfoService) LeaveGroup(userId, groupId string) (string, int) {
 	// 减少群成员计数
 	group.MemberCnt -= 1
 
+	// 管理员退群后不再保留管理权限
+	if err := group.RemoveAdmins(userId); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+
 	// 保存更新后的群聊信息
 	if res := dao.GormDB.Save(&group); res.Error != nil {
 		// 数据库保存失败，记录错误日志并返回系统错误
@@ -417,6 +465,13 @@ func (g *groupInfoService) LeaveGroup(userId, groupId string) (string, int) {
 //   - string: 操作结果消息，成功或失败的具体描述
 //   - int: 状态码，0表示成功，负数表示错误
 func (g *groupInfoService) DismissGroup(ownerId, groupId string) (string, int) {
+	// 查询群聊信息，解散后用于通知群成员
+	var group model.GroupInfo
+	if res := dao.GormDB.First(&group, "uuid = ?", groupId); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+
 	// 创建软删除的时间戳
 	var deletedAt gorm.DeletedAt
 	deletedAt.Time = time.Now()
@@ -519,6 +574,15 @@ func (g *groupInfoService) DismissGroup(ownerId, groupId string) (string, int) {
 		zlog.Error(err.Error())
 	}
 
+	// 通知除群主外的所有群成员
+	for _, userContact := range userContactList {
+		if userContact.UserId == ownerId {
+			continue
+		}
+		NotificationService.Notify(userContact.UserId, notification_type_enum.GROUP_DISMISSED,
+			fmt.Sprintf("群聊%s已被群主解散", group.Name), groupId)
+	}
+
 	// 返回解散群聊成功的消息
 	return "解散群聊成功", 0
 }
@@ -547,14 +611,16 @@ func (g *groupInfoService) GetGroupInfo(groupId string) (string, *respond.GetGro
 
 			// 构造群聊信息响应对象
 			rsp := &respond.GetGroupInfoRespond{
-				Uuid:      group.Uuid,      // 群聊UUID
-				Name:      group.Name,      // 群聊名称
-				Notice:    group.Notice,    // 群公告
-				Avatar:    group.Avatar,    // 群头像
-				MemberCnt: group.MemberCnt, // 群成员数量
-				OwnerId:   group.OwnerId,   // 群主ID
-				AddMode:   group.AddMode,   // 加群方式
-				Status:    group.Status,    // 群状态
+				Uuid:      group.Uuid,         // 群聊UUID
+				Name:      group.Name,         // 群聊名称
+				Notice:    group.Notice,       // 群公告
+				Avatar:    group.Avatar,       // 群头像
+				MemberCnt: group.MemberCnt,    // 群成员数量
+				OwnerId:   group.OwnerId,      // 群主ID
+				Admins:    group.AdminList(),  // 群管理员ID列表
+				MuteAll:   group.MuteAll == 1, // 是否全员禁言
+				AddMode:   group.AddMode,      // 加群方式
+				Status:    group.Status,       // 群状态
 			}
 
 			// 检查群聊是否已被软删除
@@ -836,6 +902,11 @@ func (g *groupInfoService) RemoveGroupMembers(req request.RemoveGroupMembersRequ
 
 	// 重新序列化更新后的成员列表
 	group.Members, _ = json.Marshal(members)
+	// 被移出群聊的管理员不再保留管理权限
+	if err := group.RemoveAdmins(req.UuidList...); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
 	// 保存更新后的群聊信息到数据库
 	if res := dao.GormDB.Save(&group); res.Error != nil {
 		// 数据库保存失败，记录错误日志并返回系统错误
@@ -863,6 +934,12 @@ func (g *groupInfoService) RemoveGroupMembers(req request.RemoveGroupMembersRequ
 		zlog.Error(err.Error())
 	}
 
+	// 通知被移除的成员
+	for _, uuid := range req.UuidList {
+		NotificationService.Notify(uuid, notification_type_enum.GROUP_KICKED,
+			fmt.Sprintf("你已被移出群聊%s", group.Name), req.GroupId)
+	}
+
 	// 返回移除群聊成员成功的消息
 	return "移除群聊成员成功", 0
 }
@@ -1077,3 +1154,444 @@ func (g *groupInfoService) SetGroupsStatus(uuidList []string, status int8) (stri
 	// 返回设置成功的消息
 	return "设置成功", 0
 }
+
+// SetGroupAdmins 设置或取消群管理员
+// 只有群主可以设置管理员，被设置的用户必须是群成员
+// 参数: req - 包含群聊ID、操作者ID、目标用户ID列表以及设置还是取消
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示没有权限或目标不合法
+func (g *groupInfoService) SetGroupAdmins(req request.SetGroupAdminsRequest) (string, int) {
+	var group model.GroupInfo
+	if res := dao.GormDB.First(&group, "uuid = ?", req.GroupId); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if req.OwnerId != group.OwnerId {
+		return "只有群主可以设置管理员", -2
+	}
+
+	var members []string
+	if err := json.Unmarshal(group.Members, &members); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	memberSet := make(map[string]bool, len(members))
+	for _, member := range members {
+		memberSet[member] = true
+	}
+
+	admins := group.AdminList()
+	for _, uuid := range req.UuidList {
+		if uuid == group.OwnerId {
+			return "群主不需要设置为管理员", -2
+		}
+		if !memberSet[uuid] {
+			return "只能将群成员设置为管理员", -2
+		}
+	}
+	if req.IsAdmin {
+		for _, uuid := range req.UuidList {
+			if !group.IsManager(uuid) {
+				admins = append(admins, uuid)
+			}
+		}
+	} else {
+		removed := make(map[string]bool, len(req.UuidList))
+		for _, uuid := range req.UuidList {
+			removed[uuid] = true
+		}
+		remaining := make([]string, 0, len(admins))
+		for _, admin := range admins {
+			if !removed[admin] {
+				remaining = append(remaining, admin)
+			}
+		}
+		admins = remaining
+	}
+
+	adminsJson, err := json.Marshal(admins)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if res := dao.GormDB.Model(&group).Update("admins", adminsJson); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if err := myredis.DelKeysWithPattern("group_info_" + req.GroupId); err != nil {
+		zlog.Error(err.Error())
+	}
+
+	content := fmt.Sprintf("你已被设置为群聊%s的管理员", group.Name)
+	if !req.IsAdmin {
+		content = fmt.Sprintf("你已被取消群聊%s的管理员", group.Name)
+	}
+	for _, uuid := range req.UuidList {
+		NotificationService.Notify(uuid, notification_type_enum.GROUP_ADMIN, content, req.GroupId)
+	}
+	return "设置成功", 0
+}
+
+// MuteGroupMembers 禁言群成员
+// 群主和管理员可以禁言普通成员，管理员只能由群主禁言，群主不能被禁言
+// 禁言到期后由RunMuteSweeper自动解除并通知成员
+// 参数: req - 包含群聊ID、操作者ID、目标用户ID列表和禁言时长
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示没有权限或目标不合法
+func (g *groupInfoService) MuteGroupMembers(req request.MuteGroupMembersRequest) (string, int) {
+	if req.Minutes < 0 {
+		return "禁言时长不合法", -2
+	}
+	group, message, ret := g.checkMuteOperator(req.GroupId, req.OwnerId, req.UuidList)
+	if ret != 0 {
+		return message, ret
+	}
+
+	// 禁言截止时间，永久禁言时为空
+	var muteUntil sql.NullTime
+	content := fmt.Sprintf("你已在群聊%s中被禁言", group.Name)
+	if req.Minutes > 0 {
+		muteUntil = sql.NullTime{Time: time.Now().Add(time.Duration(req.Minutes) * time.Minute), Valid: true}
+		content = fmt.Sprintf("你已在群聊%s中被禁言%d分钟，%s后解除", group.Name, req.Minutes, muteUntil.Time.Format("2006-01-02 15:04:05"))
+	}
+
+	if res := dao.GormDB.Model(&model.UserContact{}).
+		Where("user_id IN (?) AND contact_id = ? AND status IN (?)", req.UuidList, req.GroupId, []int8{contact_status_enum.NORMAL, contact_status_enum.SILENCE}).
+		Updates(map[string]interface{}{
+			"status":     contact_status_enum.SILENCE,
+			"mute_until": muteUntil,
+		}); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+
+	for _, uuid := range req.UuidList {
+		NotificationService.Notify(uuid, notification_type_enum.GROUP_MUTED, content, req.GroupId)
+	}
+	return "禁言成功", 0
+}
+
+// UnmuteGroupMembers 解除群成员禁言
+// 权限与禁言相同
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示没有权限或目标不合法
+func (g *groupInfoService) UnmuteGroupMembers(req request.UnmuteGroupMembersRequest) (string, int) {
+	group, message, ret := g.checkMuteOperator(req.GroupId, req.OwnerId, req.UuidList)
+	if ret != 0 {
+		return message, ret
+	}
+
+	// 只通知确实处于禁言状态的成员
+	var muted []string
+	if res := dao.GormDB.Model(&model.UserContact{}).
+		Where("user_id IN (?) AND contact_id = ? AND status = ?", req.UuidList, req.GroupId, contact_status_enum.SILENCE).
+		Pluck("user_id", &muted); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if len(muted) == 0 {
+		return "解除禁言成功", 0
+	}
+	if res := dao.GormDB.Model(&model.UserContact{}).
+		Where("user_id IN (?) AND contact_id = ? AND status = ?", muted, req.GroupId, contact_status_enum.SILENCE).
+		Updates(map[string]interface{}{
+			"status":     contact_status_enum.NORMAL,
+			"mute_until": nil,
+		}); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+
+	for _, uuid := range muted {
+		NotificationService.Notify(uuid, notification_type_enum.GROUP_UNMUTED,
+			fmt.Sprintf("你在群聊%s中的禁言已被解除", group.Name), req.GroupId)
+	}
+	return "解除禁言成功", 0
+}
+
+// muteSweepInterval 检查到期禁言的间隔
+const muteSweepInterval = time.Minute
+
+// RunMuteSweeper 定期解除已到期的禁言并通知成员
+// 每个节点都会运行，以条件更新保证同一成员的禁言只被一个节点解除和通知
+func (g *groupInfoService) RunMuteSweeper() {
+	ticker := time.NewTicker(muteSweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		g.liftExpiredMutes()
+	}
+}
+
+// liftExpiredMutes 解除所有已到期的禁言，永久禁言不受影响
+func (g *groupInfoService) liftExpiredMutes() {
+	defer func() {
+		if r := recover(); r != nil {
+			zlog.Error(fmt.Sprintf("mute sweeper panic: %v", r))
+		}
+	}()
+	var expired []model.UserContact
+	if res := dao.GormDB.Where("status = ? AND mute_until IS NOT NULL AND mute_until <= ?", contact_status_enum.SILENCE, time.Now()).
+		Find(&expired); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	groupIds := make([]string, 0, len(expired))
+	for _, member := range expired {
+		groupIds = append(groupIds, member.ContactId)
+	}
+	var groups []model.GroupInfo
+	if res := dao.GormDB.Select("uuid", "name").Where("uuid IN (?)", groupIds).Find(&groups); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return
+	}
+	groupNames := make(map[string]string, len(groups))
+	for _, group := range groups {
+		groupNames[group.Uuid] = group.Name
+	}
+
+	for _, member := range expired {
+		// 禁言期间可能被重新禁言或解除，只解除查询时的那次禁言
+		res := dao.GormDB.Model(&model.UserContact{}).
+			Where("id = ? AND status = ? AND mute_until = ?", member.Id, contact_status_enum.SILENCE, member.MuteUntil.Time).
+			Updates(map[string]interface{}{
+				"status":     contact_status_enum.NORMAL,
+				"mute_until": nil,
+			})
+		if res.Error != nil {
+			zlog.Error(res.Error.Error())
+			continue
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		NotificationService.Notify(member.UserId, notification_type_enum.GROUP_UNMUTED,
+			fmt.Sprintf("你在群聊%s中的禁言已到期解除", groupNames[member.ContactId]), member.ContactId)
+	}
+}
+
+// SetGroupMuteAll 开启或关闭全员禁言
+// 全员禁言期间只有群主和管理员可以发言，群主和管理员都可以操作
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示没有权限
+func (g *groupInfoService) SetGroupMuteAll(req request.SetGroupMuteAllRequest) (string, int) {
+	var group model.GroupInfo
+	if res := dao.GormDB.First(&group, "uuid = ?", req.GroupId); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if !group.IsManager(req.OwnerId) || !group.HasMember(req.OwnerId) {
+		return "只有群主和管理员可以设置全员禁言", -2
+	}
+
+	var muteAll int8
+	content := fmt.Sprintf("群聊%s已关闭全员禁言", group.Name)
+	if req.MuteAll {
+		muteAll = 1
+		content = fmt.Sprintf("群聊%s已开启全员禁言，仅群主和管理员可以发言", group.Name)
+	}
+	if res := dao.GormDB.Model(&group).Update("mute_all", muteAll); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if err := myredis.DelKeysWithPattern("group_info_" + req.GroupId); err != nil {
+		zlog.Error(err.Error())
+	}
+
+	// 通知受影响的普通成员
+	var members []string
+	if err := json.Unmarshal(group.Members, &members); err != nil {
+		zlog.Error(err.Error())
+		return "设置成功", 0
+	}
+	for _, member := range members {
+		if group.IsManager(member) {
+			continue
+		}
+		NotificationService.Notify(member, notification_type_enum.GROUP_MUTE_ALL, content, req.GroupId)
+	}
+	return "设置成功", 0
+}
+
+// checkMuteOperator 校验操作者能否禁言或解除禁言目标成员
+// 返回查询到的群聊，以及校验失败时的提示和状态码
+func (g *groupInfoService) checkMuteOperator(groupId string, operatorId string, uuidList []string) (*model.GroupInfo, string, int) {
+	group, message, ret := g.loadManagedGroup(groupId, operatorId)
+	if ret != 0 {
+		return nil, message, ret
+	}
+	for _, uuid := range uuidList {
+		if !group.HasMember(uuid) {
+			return nil, "只能禁言群成员", -2
+		}
+		if uuid == group.OwnerId {
+			return nil, "不能禁言群主", -2
+		}
+		if group.IsManager(uuid) && operatorId != group.OwnerId {
+			return nil, "只有群主可以禁言管理员", -2
+		}
+	}
+	return group, "", 0
+}
+
+// loadManagedGroup 查询群聊并校验操作者是群主或管理员
+// 操作者还必须仍是群成员，避免管理员列表中残留的已退群用户继续管理群聊
+// 返回查询到的群聊，以及校验失败时的提示和状态码
+func (g *groupInfoService) loadManagedGroup(groupId string, operatorId string) (*model.GroupInfo, string, int) {
+	var group model.GroupInfo
+	if res := dao.GormDB.First(&group, "uuid = ?", groupId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return nil, "群聊不存在", -2
+		}
+		zlog.Error(res.Error.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	if !group.IsManager(operatorId) || !group.HasMember(operatorId) {
+		return nil, "只有群主和管理员可以进行此操作", -2
+	}
+	return &group, "", 0
+}
+
+// GetJoinApplyList 获取群聊的加群申请列表
+// 群主和管理员可以查看，按最近申请时间倒序返回所有申请，包括已处理的申请
+// 参数: req - 包含群聊ID和操作者ID
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - []respond.JoinApplyRespond: 加群申请列表
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示没有权限
+func (g *groupInfoService) GetJoinApplyList(req request.GetJoinApplyListRequest) (string, []respond.JoinApplyRespond, int) {
+	if _, message, ret := g.loadManagedGroup(req.GroupId, req.OwnerId); ret != 0 {
+		return message, nil, ret
+	}
+
+	var applyList []model.ContactApply
+	if res := dao.GormDB.Where("contact_id = ?", req.GroupId).Order("last_apply_at DESC").Find(&applyList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+
+	rsp := make([]respond.JoinApplyRespond, 0, len(applyList))
+	for _, apply := range applyList {
+		var user model.UserInfo
+		if res := dao.GormDB.First(&user, "uuid = ?", apply.UserId); res.Error != nil {
+			// 申请人已被删除，跳过该申请
+			zlog.Error(res.Error.Error())
+			continue
+		}
+		rsp = append(rsp, respond.JoinApplyRespond{
+			ApplyId:     apply.Uuid,
+			UserId:      user.Uuid,
+			Nickname:    user.Nickname,
+			Avatar:      user.Avatar,
+			Message:     apply.Message,
+			Status:      apply.Status,
+			LastApplyAt: apply.LastApplyAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return "获取成功", rsp, 0
+}
+
+// PassJoinApply 通过加群申请
+// 群主和管理员可以处理，通过后自动为申请人创建群成员关系并通知申请人
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示没有权限或申请已处理
+func (g *groupInfoService) PassJoinApply(req request.HandleJoinApplyRequest) (string, int) {
+	if _, message, ret := g.loadPendingJoinApply(req); ret != 0 {
+		return message, ret
+	}
+
+	// 申请期间已通过其他方式入群时，只更新申请状态，避免重复创建成员关系
+	isMember, err := isGroupMember(req.GroupId, req.ApplicantId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if isMember {
+		if res := dao.GormDB.Model(&model.ContactApply{}).Where("contact_id = ? AND user_id = ?", req.GroupId, req.ApplicantId).
+			Update("status", contact_apply_status_enum.AGREE); res.Error != nil {
+			zlog.Error(res.Error.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+		return "该用户已是群成员", 0
+	}
+
+	// 创建群成员关系、更新群成员列表并通知申请人
+	message, ret := UserContactService.PassContactApply(req.GroupId, req.ApplicantId)
+	if ret != 0 {
+		return message, ret
+	}
+
+	// 群成员变化，清除群聊信息和成员列表缓存
+	if err := myredis.DelKeysWithPattern("group_info_" + req.GroupId); err != nil {
+		zlog.Error(err.Error())
+	}
+	if err := myredis.DelKeysWithPattern("group_memberlist_" + req.GroupId); err != nil {
+		zlog.Error(err.Error())
+	}
+	return message, 0
+}
+
+// RefuseJoinApply 拒绝加群申请
+// 被拒绝的申请人可以重新申请
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示没有权限或申请已处理
+func (g *groupInfoService) RefuseJoinApply(req request.HandleJoinApplyRequest) (string, int) {
+	group, message, ret := g.loadPendingJoinApply(req)
+	if ret != 0 {
+		return message, ret
+	}
+	if message, ret := UserContactService.RefuseContactApply(req.GroupId, req.ApplicantId); ret != 0 {
+		return message, ret
+	}
+	NotificationService.Notify(req.ApplicantId, notification_type_enum.CONTACT_REFUSE,
+		fmt.Sprintf("你加入群聊%s的申请被拒绝", group.Name), req.GroupId)
+	return "已拒绝该加群申请", 0
+}
+
+// BlackJoinApply 拒绝加群申请并禁止申请人再次申请
+// 申请人收到的通知与普通拒绝相同
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示没有权限或申请已处理
+func (g *groupInfoService) BlackJoinApply(req request.HandleJoinApplyRequest) (string, int) {
+	group, message, ret := g.loadPendingJoinApply(req)
+	if ret != 0 {
+		return message, ret
+	}
+	if message, ret := UserContactService.BlackApply(req.GroupId, req.ApplicantId); ret != 0 {
+		return message, ret
+	}
+	NotificationService.Notify(req.ApplicantId, notification_type_enum.CONTACT_REFUSE,
+		fmt.Sprintf("你加入群聊%s的申请被拒绝", group.Name), req.GroupId)
+	return "已拉黑该申请", 0
+}
+
+// loadPendingJoinApply 校验操作者是群主或管理员，且申请人有待处理的加群申请
+// 返回查询到的群聊，以及校验失败时的提示和状态码
+func (g *groupInfoService) loadPendingJoinApply(req request.HandleJoinApplyRequest) (*model.GroupInfo, string, int) {
+	group, message, ret := g.loadManagedGroup(req.GroupId, req.OwnerId)
+	if ret != 0 {
+		return nil, message, ret
+	}
+	var apply model.ContactApply
+	if res := dao.GormDB.Where("contact_id = ? AND user_id = ?", req.GroupId, req.ApplicantId).First(&apply); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return nil, "加群申请不存在", -2
+		}
+		zlog.Error(res.Error.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	if apply.Status != contact_apply_status_enum.PENDING {
+		return nil, "该申请已被处理", -2
+	}
+	return group, "", 0
+}
@@ -0,0 +1,306 @@
+package gorm
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"gochat/internal/dao"
+	"gochat/internal/dto/request"
+	"gochat/internal/dto/respond"
+	"gochat/internal/model"
+	myredis "gochat/internal/service/redis"
+	"gochat/pkg/constants"
+	"gochat/pkg/enum/contact/contact_status_enum"
+	"gochat/pkg/enum/contact/contact_type_enum"
+	"gochat/pkg/enum/contact_apply/contact_apply_status_enum"
+	"gochat/pkg/enum/group_info/add_mode_enum"
+	"gochat/pkg/enum/group_info/group_status_enum"
+	"gochat/pkg/enum/group_invite/group_invite_status_enum"
+	"gochat/pkg/enum/notification/notification_type_enum"
+	"gochat/pkg/enum/user_info/user_status_enum"
+	"gochat/pkg/util/random"
+	"gochat/pkg/zlog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type groupInviteService struct {
+}
+
+var GroupInviteService = new(groupInviteService)
+
+// inviteCodeLength 邀请码长度
+const inviteCodeLength = 8
+
+// InviteGroupMembers 群成员邀请自己的好友加入群聊
+// 直接加入的群聊，或者邀请人是群主或管理员时，被邀请人直接入群；
+// 需要审核的群聊由普通成员邀请时，为被邀请人提交加群申请，由群主和管理员审核
+// 已在群内、不是邀请人好友或已被禁止加入的用户会被跳过
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - respond.InviteGroupMembersRespond: 直接入群和等待审核的用户列表
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示群聊不存在或邀请人不在群内
+func (gi *groupInviteService) InviteGroupMembers(req request.InviteGroupMembersRequest) (string, respond.InviteGroupMembersRespond, int) {
+	rsp := respond.InviteGroupMembersRespond{Joined: []string{}, Pending: []string{}}
+	var group model.GroupInfo
+	if res := dao.GormDB.First(&group, "uuid = ?", req.GroupId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "群聊不存在", rsp, -2
+		}
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, rsp, -1
+	}
+	isMember, err := isGroupMember(group.Uuid, req.OwnerId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, rsp, -1
+	}
+	if !isMember {
+		return "你不是该群成员", rsp, -2
+	}
+
+	inviterName := nicknameOf(req.OwnerId)
+	direct := group.AddMode == add_mode_enum.DIRECT || group.IsManager(req.OwnerId)
+	for _, uuid := range req.UuidList {
+		if !gi.canInvite(req.OwnerId, uuid) {
+			continue
+		}
+		if message, ret := checkCanJoinGroup(&group, uuid); ret != 0 {
+			if ret == -1 {
+				return message, rsp, ret
+			}
+			continue
+		}
+
+		if direct {
+			if message, ret := addGroupMember(&group, uuid); ret != 0 {
+				return message, rsp, ret
+			}
+			NotificationService.Notify(uuid, notification_type_enum.GROUP_INVITED,
+				fmt.Sprintf("%s邀请你加入了群聊%s", inviterName, group.Name), group.Uuid)
+			rsp.Joined = append(rsp.Joined, uuid)
+			continue
+		}
+
+		if message, ret := gi.submitInviteApply(&group, uuid, fmt.Sprintf("由%s邀请", inviterName)); ret != 0 {
+			return message, rsp, ret
+		}
+		NotificationService.Notify(uuid, notification_type_enum.GROUP_INVITED,
+			fmt.Sprintf("%s邀请你加入群聊%s，等待群主或管理员审核", inviterName, group.Name), group.Uuid)
+		rsp.Pending = append(rsp.Pending, uuid)
+	}
+	return "邀请成功", rsp, 0
+}
+
+// canInvite 判断被邀请人是否是邀请人的好友且账号正常，拉黑关系中的双方不能互相邀请
+func (gi *groupInviteService) canInvite(inviterId string, inviteeId string) bool {
+	var contact model.UserContact
+	if res := dao.GormDB.Where("user_id = ? AND contact_id = ? AND contact_type = ?", inviterId, inviteeId, contact_type_enum.USER).
+		First(&contact); res.Error != nil {
+		if !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			zlog.Error(res.Error.Error())
+		}
+		return false
+	}
+	if contact.Status != contact_status_enum.NORMAL && contact.Status != contact_status_enum.SILENCE {
+		return false
+	}
+	var user model.UserInfo
+	if res := dao.GormDB.First(&user, "uuid = ?", inviteeId); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return false
+	}
+	return user.Status != user_status_enum.DISABLE
+}
+
+// submitInviteApply 为被邀请人提交加群申请并通知群主和管理员审核，已有申请记录时重置为待处理
+func (gi *groupInviteService) submitInviteApply(group *model.GroupInfo, userId string, message string) (string, int) {
+	var contactApply model.ContactApply
+	if res := dao.GormDB.Where("user_id = ? AND contact_id = ?", userId, group.Uuid).First(&contactApply); res.Error != nil {
+		if !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			zlog.Error(res.Error.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+		contactApply = model.ContactApply{
+			Uuid:        fmt.Sprintf("A%s", random.GetNowAndLenRandomString(11)),
+			UserId:      userId,
+			ContactId:   group.Uuid,
+			ContactType: contact_type_enum.GROUP,
+		}
+	}
+	contactApply.Status = contact_apply_status_enum.PENDING
+	contactApply.Message = message
+	contactApply.LastApplyAt = time.Now()
+	if res := dao.GormDB.Save(&contactApply); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if err := myredis.DelKeysWithPattern("new_contact_list_" + group.Uuid); err != nil {
+		zlog.Error(err.Error())
+	}
+
+	content := fmt.Sprintf("%s申请加入群聊%s：%s", nicknameOf(userId), group.Name, message)
+	for _, manager := range append([]string{group.OwnerId}, group.AdminList()...) {
+		NotificationService.Notify(manager, notification_type_enum.CONTACT_APPLY, content, group.Uuid)
+	}
+	return "", 0
+}
+
+// CreateGroupInvite 创建群聊邀请链接
+// 只有群主和管理员可以创建，通过邀请链接加入无需审核
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - respond.GroupInviteRespond: 创建的邀请链接
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示参数错误或没有权限
+func (gi *groupInviteService) CreateGroupInvite(req request.CreateGroupInviteRequest) (string, respond.GroupInviteRespond, int) {
+	if req.ExpireHours < 0 || req.MaxUses < 0 {
+		return "有效时长和使用次数不能为负数", respond.GroupInviteRespond{}, -2
+	}
+	group, message, ret := GroupInfoService.loadManagedGroup(req.GroupId, req.OwnerId)
+	if ret != 0 {
+		return message, respond.GroupInviteRespond{}, ret
+	}
+	if group.Status != group_status_enum.NORMAL {
+		return "群聊已被禁用", respond.GroupInviteRespond{}, -2
+	}
+
+	code, err := random.GetRandomCode(inviteCodeLength)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, respond.GroupInviteRespond{}, -1
+	}
+	invite := model.GroupInvite{
+		Uuid:      fmt.Sprintf("I%s", random.GetNowAndLenRandomString(11)), // 生成邀请链接唯一标识，以'I'开头
+		GroupId:   group.Uuid,
+		CreatorId: req.OwnerId,
+		Code:      code,
+		MaxUses:   req.MaxUses,
+		Status:    group_invite_status_enum.VALID,
+		CreatedAt: time.Now(),
+	}
+	if req.ExpireHours > 0 {
+		invite.ExpireAt = sql.NullTime{Time: invite.CreatedAt.Add(time.Duration(req.ExpireHours) * time.Hour), Valid: true}
+	}
+	if res := dao.GormDB.Create(&invite); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, respond.GroupInviteRespond{}, -1
+	}
+	return "创建成功", toGroupInviteRespond(invite), 0
+}
+
+// GetGroupInviteList 获取群聊的邀请链接列表，包括已撤销和已失效的链接
+// 只有群主和管理员可以查看
+func (gi *groupInviteService) GetGroupInviteList(req request.GetGroupInviteListRequest) (string, []respond.GroupInviteRespond, int) {
+	if _, message, ret := GroupInfoService.loadManagedGroup(req.GroupId, req.OwnerId); ret != 0 {
+		return message, nil, ret
+	}
+	var inviteList []model.GroupInvite
+	if res := dao.GormDB.Where("group_id = ?", req.GroupId).Order("created_at DESC").Find(&inviteList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	rsp := make([]respond.GroupInviteRespond, 0, len(inviteList))
+	for _, invite := range inviteList {
+		rsp = append(rsp, toGroupInviteRespond(invite))
+	}
+	return "获取成功", rsp, 0
+}
+
+// RevokeGroupInvite 撤销邀请链接，撤销后链接立即失效
+// 只有群主和管理员可以撤销
+func (gi *groupInviteService) RevokeGroupInvite(req request.RevokeGroupInviteRequest) (string, int) {
+	var invite model.GroupInvite
+	if res := dao.GormDB.First(&invite, "uuid = ?", req.InviteId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "邀请链接不存在", -2
+		}
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if _, message, ret := GroupInfoService.loadManagedGroup(invite.GroupId, req.OwnerId); ret != 0 {
+		return message, ret
+	}
+	if res := dao.GormDB.Model(&invite).Update("status", group_invite_status_enum.REVOKED); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	return "撤销成功", 0
+}
+
+// JoinGroupByInvite 通过邀请码加入群聊
+// 校验邀请码未撤销、未过期且未达到使用次数上限，通过邀请链接加入无需审核
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - string: 加入的群聊ID
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示邀请码无效或不能加入
+func (gi *groupInviteService) JoinGroupByInvite(req request.JoinGroupByInviteRequest) (string, string, int) {
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	var invite model.GroupInvite
+	if res := dao.GormDB.First(&invite, "code = ?", code); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "邀请码无效", "", -2
+		}
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, "", -1
+	}
+	if invite.Status == group_invite_status_enum.REVOKED {
+		return "邀请链接已被撤销", "", -2
+	}
+	if invite.ExpireAt.Valid && time.Now().After(invite.ExpireAt.Time) {
+		return "邀请链接已过期", "", -2
+	}
+
+	var group model.GroupInfo
+	if res := dao.GormDB.First(&group, "uuid = ?", invite.GroupId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "群聊不存在", "", -2
+		}
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, "", -1
+	}
+	if message, ret := checkCanJoinGroup(&group, req.OwnerId); ret != 0 {
+		return message, "", ret
+	}
+
+	// 以条件更新占用一次使用次数，并发使用同一邀请码时不会超过上限
+	res := dao.GormDB.Model(&model.GroupInvite{}).
+		Where("id = ? AND status = ? AND (max_uses = 0 OR used_count < max_uses)", invite.Id, group_invite_status_enum.VALID).
+		Update("used_count", gorm.Expr("used_count + 1"))
+	if res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, "", -1
+	}
+	if res.RowsAffected == 0 {
+		return "邀请链接已达到使用次数上限", "", -2
+	}
+
+	if message, ret := addGroupMember(&group, req.OwnerId); ret != 0 {
+		// 入群失败时归还占用的使用次数
+		if res := dao.GormDB.Model(&model.GroupInvite{}).Where("id = ?", invite.Id).
+			Update("used_count", gorm.Expr("used_count - 1")); res.Error != nil {
+			zlog.Error(res.Error.Error())
+		}
+		return message, "", ret
+	}
+	return "进群成功", group.Uuid, 0
+}
+
+// toGroupInviteRespond 将邀请链接模型转换为响应对象
+func toGroupInviteRespond(invite model.GroupInvite) respond.GroupInviteRespond {
+	rsp := respond.GroupInviteRespond{
+		InviteId:  invite.Uuid,
+		GroupId:   invite.GroupId,
+		CreatorId: invite.CreatorId,
+		Code:      invite.Code,
+		MaxUses:   invite.MaxUses,
+		UsedCount: invite.UsedCount,
+		Status:    invite.Status,
+		CreatedAt: invite.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if invite.ExpireAt.Valid {
+		rsp.ExpireAt = invite.ExpireAt.Time.Format("2006-01-02 15:04:05")
+	}
+	return rsp
+}
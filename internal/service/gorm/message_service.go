@@ -1,23 +1,29 @@
 package gorm
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"gochat/internal/config"
 	"gochat/internal/dao"
+	"gochat/internal/dto/request"
 	"gochat/internal/dto/respond"
 	"gochat/internal/model"
 	myredis "gochat/internal/service/redis"
+	"gochat/internal/service/storage"
 	"gochat/pkg/constants"
+	"gochat/pkg/enum/contact/contact_status_enum"
+	"gochat/pkg/enum/file/file_category_enum"
 	"gochat/pkg/zlog"
-	"io"
-	"os"
-	"path/filepath"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
 )
 
 type messageService struct {
@@ -66,6 +72,12 @@ func (m *messageService) GetMessageList(userOneId, userTwoId string) (string, []
 					FileName:   message.FileName,                                // 文件名
 					FileSize:   message.FileSize,                                // 文件大小
 					CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"), // 创建时间，格式化为"年-月-日 时:分:秒"
+
+					Width:        message.Width,        // 图片宽度
+					Height:       message.Height,       // 图片高度
+					Duration:     message.Duration,     // 音频时长
+					ThumbnailUrl: message.ThumbnailUrl, // 缩略图固定访问路径，返回前再签名
+					AVdata:       message.AVdata,       // 通话记录
 				})
 			}
 
@@ -78,6 +90,7 @@ func (m *messageService) GetMessageList(userOneId, userTwoId string) (string, []
 			if err := myredis.SetKeyEx("message_list_"+userOneId+"_"+userTwoId, string(rspString), time.Minute*constants.REDIS_TIMEOUT); err != nil {
 				zlog.Error(err.Error())
 			}
+			signMessageThumbnails(rspList, userOneId)
 			return "获取聊天记录成功", rspList, 0
 		} else {
 			// Redis连接错误
@@ -92,18 +105,29 @@ func (m *messageService) GetMessageList(userOneId, userTwoId string) (string, []
 		// 反序列化失败，记录错误日志
 		zlog.Error(err.Error())
 	}
+	signMessageThumbnails(rsp, userOneId)
 
 	return "获取群聊记录成功", rsp, 0
 }
 
 // GetGroupMessageList 获取群聊消息记录
 // 功能：获取指定群聊的消息记录，优先从Redis缓存获取，若缓存不存在则从数据库查询
-// 参数：groupId - 群聊ID
+// 只有群成员可以获取，缩略图地址签名给请求的用户
+// 参数：ownerId - 请求的用户ID，groupId - 群聊ID
 // 返回值:
 //   - string: 操作结果消息，成功或失败的具体描述
 //   - []respond.GetGroupMessageListRespond: 群聊消息记录响应对象数组
-//   - int: 状态码，0表示成功，-1表示系统错误
-func (m *messageService) GetGroupMessageList(groupId string) (string, []respond.GetGroupMessageListRespond, int) {
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示不是群成员
+func (m *messageService) GetGroupMessageList(ownerId, groupId string) (string, []respond.GetGroupMessageListRespond, int) {
+	isMember, err := isGroupMember(groupId, ownerId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	if !isMember {
+		return "你不是该群成员", nil, -2
+	}
+
 	// 尝试从Redis缓存中获取群聊消息记录
 	rspString, err := myredis.GetKeyNilIsErr("group_messagelist_" + groupId)
 	if err != nil {
@@ -133,6 +157,12 @@ func (m *messageService) GetGroupMessageList(groupId string) (string, []respond.
 					FileName:   message.FileName,                                // 文件名
 					FileSize:   message.FileSize,                                // 文件大小
 					CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"), // 创建时间，格式化为"年-月-日 时:分:秒"
+
+					Width:        message.Width,        // 图片宽度
+					Height:       message.Height,       // 图片高度
+					Duration:     message.Duration,     // 音频时长
+					ThumbnailUrl: message.ThumbnailUrl, // 缩略图固定访问路径，返回前再签名
+					AVdata:       message.AVdata,       // 通话记录
 				}
 				// 将单条消息响应对象添加到响应对象数组中
 				rspList = append(rspList, rsp)
@@ -147,6 +177,8 @@ func (m *messageService) GetGroupMessageList(groupId string) (string, []respond.
 			if err := myredis.SetKeyEx("group_messagelist_"+groupId, string(rspString), time.Minute*constants.REDIS_TIMEOUT); err != nil {
 				zlog.Error(err.Error())
 			}
+			rspList = excludeBlockedSenders(rspList, ownerId)
+			signGroupMessageThumbnails(rspList, ownerId)
 			return "获取聊天记录成功", rspList, 0
 		} else {
 			// Redis连接错误
@@ -161,122 +193,227 @@ func (m *messageService) GetGroupMessageList(groupId string) (string, []respond.
 		// 反序列化失败，记录错误日志
 		zlog.Error(err.Error())
 	}
+	rsp = excludeBlockedSenders(rsp, ownerId)
+	signGroupMessageThumbnails(rsp, ownerId)
 
 	return "获取聊天记录成功", rsp, 0
 }
 
-// UploadAvatar 上传头像
-// 功能：处理用户上传头像的请求，将上传的文件保存到本地指定目录
-// 参数：c - Gin框架的上下文对象，包含HTTP请求的相关信息
-// 返回值:
-//   - string: 操作结果消息，成功或失败的具体描述
-//   - int: 状态码，0表示成功，-1表示系统错误
-func (m *messageService) UploadAvatar(c *gin.Context) (string, int) {
-	// 解析multipart form数据，设置最大文件大小限制
-	if err := c.Request.ParseMultipartForm(constants.FILE_MAX_SIZE); err != nil {
-		zlog.Error(err.Error())
-		return constants.SYSTEM_ERROR, -1
+// signMessageThumbnails 将私聊记录中缩略图的固定访问路径替换为签名下载地址
+// 缓存中保存固定访问路径，需要在写入缓存之后调用
+func signMessageThumbnails(rspList []respond.GetMessageListRespond, userId string) {
+	for i := range rspList {
+		rspList[i].ThumbnailUrl = storage.SignStaticURL(rspList[i].ThumbnailUrl, userId)
 	}
+}
 
-	// 获取multipart form数据
-	mForm := c.Request.MultipartForm
-	// 遍历上传的文件
-	for key := range mForm.File {
-		// 根据键名获取上传的文件
-		file, fileHeader, err := c.Request.FormFile(key)
-		if err != nil {
-			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
-		}
-
-		// 确保文件在函数结束时关闭
-		defer file.Close()
-
-		// 记录上传的文件名和文件大小
-		zlog.Info(fmt.Sprintf("文件名：%s，文件大小：%d", fileHeader.Filename, fileHeader.Size))
-		// 获取文件扩展名
-		// 原来Filename应该是213451545.xxx，将Filename修改为avatar_ownerId.xxx
-		ext := filepath.Ext(fileHeader.Filename)
-		zlog.Info(ext)
-
-		// 构建本地文件路径，将文件保存到配置的头像静态路径下
-		localFileName := config.GetConfig().StaticAvatarPath + "/" + fileHeader.Filename
-		// 创建本地文件用于保存上传的头像
-		out, err := os.Create(localFileName)
-		if err != nil {
-			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
-		}
+// signGroupMessageThumbnails 将群聊记录中缩略图的固定访问路径替换为签名下载地址
+// 缓存中保存固定访问路径，需要在写入缓存之后调用
+func signGroupMessageThumbnails(rspList []respond.GetGroupMessageListRespond, userId string) {
+	for i := range rspList {
+		rspList[i].ThumbnailUrl = storage.SignStaticURL(rspList[i].ThumbnailUrl, userId)
+	}
+}
 
-		// 确保输出文件在函数结束时关闭
-		defer out.Close()
-		// 将上传的文件内容复制到本地文件
-		if _, err := io.Copy(out, file); err != nil {
-			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
+// excludeBlockedSenders 去掉群聊记录中被userId拉黑的用户发送的消息，被拉黑用户的群消息对拉黑者不可见
+// 缓存由所有群成员共享，保存未过滤的记录，需要在写入缓存之后调用；查询失败时返回原记录
+func excludeBlockedSenders(rspList []respond.GetGroupMessageListRespond, userId string) []respond.GetGroupMessageListRespond {
+	var blocked []string
+	if res := dao.GormDB.Model(&model.UserContact{}).
+		Where("user_id = ? AND status = ?", userId, contact_status_enum.BLACK).
+		Pluck("contact_id", &blocked); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return rspList
+	}
+	if len(blocked) == 0 {
+		return rspList
+	}
+	blockedSet := make(map[string]struct{}, len(blocked))
+	for _, contactId := range blocked {
+		blockedSet[contactId] = struct{}{}
+	}
+	visible := make([]respond.GetGroupMessageListRespond, 0, len(rspList))
+	for _, rsp := range rspList {
+		if _, ok := blockedSet[rsp.SendId]; !ok {
+			visible = append(visible, rsp)
 		}
-
-		// 记录文件上传完成日志
-		zlog.Info("完成文件上传")
 	}
+	return visible
+}
 
-	return "上传成功", 0
+// UploadAvatar 上传头像
+// 功能：处理用户上传头像的请求，文件以内容哈希命名保存到对象存储的头像目录，内容相同的头像只保存一份
+// 只保存图片，不修改用户或群聊资料；需要裁剪并直接设置头像时使用AvatarService
+// 参数：c - Gin框架的上下文对象，包含HTTP请求的相关信息
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - []respond.UploadFileRespond: 每个上传文件的访问地址和元数据，客户端应使用返回的url
+//   - int: 状态码，0表示成功，-1表示系统错误
+func (m *messageService) UploadAvatar(c *gin.Context) (string, []respond.UploadFileRespond, int) {
+	return m.upload(c, file_category_enum.AVATAR)
 }
 
 // UploadFile 上传文件
-// 功能：处理用户上传文件的请求，将上传的文件保存到本地指定目录
+// 功能：处理用户上传文件的请求，文件以内容哈希命名保存到对象存储的文件目录，内容相同的文件只保存一份
 // 参数：c - Gin框架的上下文对象，包含HTTP请求的相关信息
 // 返回值:
 //   - string: 操作结果消息，成功或失败的具体描述
+//   - []respond.UploadFileRespond: 每个上传文件的访问地址和元数据，客户端应将返回的url放入聊天消息
 //   - int: 状态码，0表示成功，-1表示系统错误
-func (m *messageService) UploadFile(c *gin.Context) (string, int) {
-	// 解析multipart form数据，设置最大文件大小限制
+func (m *messageService) UploadFile(c *gin.Context) (string, []respond.UploadFileRespond, int) {
+	return m.upload(c, file_category_enum.FILE)
+}
+
+// upload 将multipart form中的所有文件保存到对象存储中，uploaderId取自表单的owner_id字段
+// 较大的文件应使用分片上传，这里整个请求体不能超过该类文件的大小限制
+func (m *messageService) upload(c *gin.Context, category int8) (string, []respond.UploadFileRespond, int) {
+	if max := maxUploadBytes(category); max > 0 {
+		// 请求体还包含表单字段和分隔符，预留1MB
+		if c.Request.ContentLength > max+1024*1024 {
+			return fmt.Sprintf("文件大小不能超过%dMB，请使用分片上传", uploadLimit(category).MaxSize), nil, -2
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max+1024*1024)
+	}
+	// 解析multipart form数据，超过内存限制的部分会暂存到磁盘
 	if err := c.Request.ParseMultipartForm(constants.FILE_MAX_SIZE); err != nil {
 		zlog.Error(err.Error())
-		return constants.SYSTEM_ERROR, -1
+		return constants.SYSTEM_ERROR, nil, -1
 	}
 
-	// 获取multipart form数据
-	mForm := c.Request.MultipartForm
+	uploaderId := c.Request.FormValue("owner_id")
+	rsp := make([]respond.UploadFileRespond, 0, len(c.Request.MultipartForm.File))
 	// 遍历上传的文件
-	for key := range mForm.File {
-		// 根据键名获取上传的文件
-		file, fileHeader, err := c.Request.FormFile(key)
-		if err != nil {
-			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
+	for _, fileHeaders := range c.Request.MultipartForm.File {
+		for _, fileHeader := range fileHeaders {
+			// 记录上传的文件名和文件大小
+			zlog.Info(fmt.Sprintf("文件名：%s，文件大小：%d", fileHeader.Filename, fileHeader.Size))
+			fileRsp, err := storeUpload(fileHeader, category, uploaderId)
+			if err != nil {
+				message, ret := uploadErrorRet(err)
+				return message, nil, ret
+			}
+			rsp = append(rsp, fileRsp)
 		}
+	}
+	return "上传成功", rsp, 0
+}
 
-		// 确保文件在函数结束时关闭
-		defer file.Close()
+// GetStaticObject 解析固定访问路径/static/<key>，使用S3驱动时代替静态文件服务
+// 只提供头像等公开对象，随程序发布的默认头像直接从本地静态目录提供，其余对象重定向到对象存储的预签名下载地址
+// 参数：path - /static/之后的路径
+// 返回值:
+//   - string: 本地文件路径，不为空时直接返回该文件
+//   - string: 对象存储的下载地址
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示路径非法或对象不存在
+func (m *messageService) GetStaticObject(path string) (string, string, int) {
+	key, ok := storage.KeyFromURL(storage.StaticURL(strings.TrimPrefix(path, "/")))
+	if !ok || !storage.IsPublic(key) {
+		return "", "", -2
+	}
+	if localPath, ok := storage.BundledPath(key); ok {
+		return localPath, "", 0
+	}
 
-		// 记录上传的文件名和文件大小
-		zlog.Info(fmt.Sprintf("文件名：%s，文件大小：%d", fileHeader.Filename, fileHeader.Size))
-		// 获取文件扩展名
-		// 原来Filename应该是213451545.xxx，将Filename修改为avatar_ownerId.xxx
-		ext := filepath.Ext(fileHeader.Filename)
-		zlog.Info(ext)
+	ctx := context.Background()
+	exists, err := storage.Default.Exists(ctx, key)
+	if err != nil {
+		zlog.Error(err.Error())
+		return "", "", -1
+	}
+	if !exists {
+		return "", "", -2
+	}
+	downloadUrl, err := storage.Default.DownloadURL(ctx, key)
+	if err != nil {
+		zlog.Error(err.Error())
+		return "", "", -1
+	}
+	return "", downloadUrl, 0
+}
 
-		// 构建本地文件路径，将文件保存到配置的文件静态路径下
-		localFileName := config.GetConfig().StaticFilePath + "/" + fileHeader.Filename
-		// 创建本地文件用于保存上传的文件
-		out, err := os.Create(localFileName)
+// GetFileDownloadUrl 获取聊天文件的签名下载地址
+// 只有消息所在会话的参与者可以获取：私聊为收发双方，群聊为当前群成员
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - respond.FileDownloadUrlRespond: 签名下载地址
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示消息不存在、没有文件或没有权限
+func (m *messageService) GetFileDownloadUrl(req request.GetFileDownloadUrlRequest) (string, respond.FileDownloadUrlRespond, int) {
+	var message model.Message
+	if res := dao.GormDB.First(&message, "uuid = ?", req.MessageId); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "消息不存在", respond.FileDownloadUrlRespond{}, -2
+		}
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, respond.FileDownloadUrlRespond{}, -1
+	}
+	// 只签发聊天文件目录下的对象，消息中的地址由客户端提供，不能借此访问分片等其他对象
+	key, ok := storage.KeyFromURL(message.Url)
+	if !ok || !strings.HasPrefix(key, categoryDir(file_category_enum.FILE)+"/") {
+		return "该消息没有可下载的文件", respond.FileDownloadUrlRespond{}, -2
+	}
+	// 只签发发送者自己上传的文件，早于上传者校验保存的消息可能引用了他人的文件
+	storageName := path.Base(key)
+	var uploads int64
+	if res := dao.GormDB.Model(&model.UploadedFile{}).
+		Where("hash = ? AND storage_name = ? AND uploader_id = ?", strings.SplitN(storageName, ".", 2)[0], storageName, message.SendId).
+		Count(&uploads); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, respond.FileDownloadUrlRespond{}, -1
+	}
+	if uploads == 0 {
+		return "没有权限下载该文件", respond.FileDownloadUrlRespond{}, -2
+	}
+
+	participant := false
+	switch message.ReceiveId[0] {
+	case 'U':
+		participant = req.OwnerId == message.SendId || req.OwnerId == message.ReceiveId
+	case 'G':
+		isMember, err := isGroupMember(message.ReceiveId, req.OwnerId)
 		if err != nil {
 			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
+			return constants.SYSTEM_ERROR, respond.FileDownloadUrlRespond{}, -1
 		}
+		participant = isMember
+	}
+	if !participant {
+		return "没有权限下载该文件", respond.FileDownloadUrlRespond{}, -2
+	}
 
-		// 确保输出文件在函数结束时关闭
-		defer out.Close()
+	return "获取成功", respond.FileDownloadUrlRespond{
+		Url:      storage.SignDownloadURL(key, req.OwnerId),
+		FileName: message.FileName,
+	}, 0
+}
 
-		// 将上传的文件内容复制到本地文件
-		if _, err := io.Copy(out, file); err != nil {
-			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
-		}
+// ResolveDownload 校验签名下载地址，返回本地文件路径或对象存储的预签名下载地址
+// 参数：query - 签名下载地址的查询参数
+// 返回值:
+//   - string: 本地文件路径，使用本地驱动时不为空
+//   - string: 对象存储的预签名下载地址
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示签名无效或已过期，-3表示文件不存在
+func (m *messageService) ResolveDownload(query url.Values) (string, string, int) {
+	key, err := storage.VerifyDownloadURL(query)
+	if err != nil {
+		return "", "", -2
+	}
 
-		// 记录文件上传完成日志
-		zlog.Info("完成文件上传")
+	ctx := context.Background()
+	exists, err := storage.Default.Exists(ctx, key)
+	if err != nil {
+		zlog.Error(err.Error())
+		return "", "", -1
+	}
+	if !exists {
+		return "", "", -3
+	}
+	if localPath, ok := storage.LocalPath(key); ok {
+		return localPath, "", 0
+	}
+	downloadUrl, err := storage.Default.DownloadURL(ctx, key)
+	if err != nil {
+		zlog.Error(err.Error())
+		return "", "", -1
 	}
-	return "上传成功", 0
+	return "", downloadUrl, 0
 }
@@ -0,0 +1,115 @@
+package gorm
+
+import (
+	"database/sql"
+	"fmt"
+	"gochat/internal/dao"
+	"gochat/internal/dto/request"
+	"gochat/internal/dto/respond"
+	"gochat/internal/model"
+	"gochat/internal/service/chat"
+	"gochat/pkg/constants"
+	"gochat/pkg/enum/notification/notification_status_enum"
+	"gochat/pkg/util/random"
+	"gochat/pkg/zlog"
+	"time"
+)
+
+type notificationService struct {
+}
+
+var NotificationService = new(notificationService)
+
+// notificationListLimit 通知列表最多返回的条数，更早的通知不再展示
+const notificationListLimit = 100
+
+// Notify 向用户发送系统通知
+// 通知先写入收件箱，再通过WebSocket推送给在线用户；写入失败只记录日志，不影响触发通知的业务操作
+// 参数:
+//   - userId: 接收通知的用户ID
+//   - notificationType: 通知类型，见notification_type_enum
+//   - content: 通知内容
+//   - targetId: 通知涉及的用户或群聊ID
+func (n *notificationService) Notify(userId string, notificationType int8, content string, targetId string) {
+	notification := model.Notification{
+		Uuid:      fmt.Sprintf("N%s", random.GetNowAndLenRandomString(11)), // 生成通知唯一标识，以'N'开头
+		UserId:    userId,
+		Type:      notificationType,
+		Content:   content,
+		TargetId:  targetId,
+		Status:    notification_status_enum.UNREAD,
+		CreatedAt: time.Now(),
+	}
+	if res := dao.GormDB.Create(&notification); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return
+	}
+	chat.PushNotification(userId, toNotificationRespond(notification))
+}
+
+// GetNotificationList 获取用户的通知列表
+// 按时间倒序返回最近的通知，同时返回未读通知总数
+// 参数: ownerId - 用户ID
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - respond.GetNotificationListRespond: 未读数和通知列表
+//   - int: 状态码，0表示成功，-1表示系统错误
+func (n *notificationService) GetNotificationList(ownerId string) (string, respond.GetNotificationListRespond, int) {
+	var rsp respond.GetNotificationListRespond
+	var notificationList []model.Notification
+	if res := dao.GormDB.Where("user_id = ?", ownerId).Order("created_at DESC").Limit(notificationListLimit).Find(&notificationList); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, rsp, -1
+	}
+	if res := dao.GormDB.Model(&model.Notification{}).Where("user_id = ? AND status = ?", ownerId, notification_status_enum.UNREAD).Count(&rsp.UnreadCount); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, rsp, -1
+	}
+	rsp.NotificationList = make([]respond.NotificationRespond, 0, len(notificationList))
+	for _, notification := range notificationList {
+		rsp.NotificationList = append(rsp.NotificationList, toNotificationRespond(notification))
+	}
+	return "获取通知列表成功", rsp, 0
+}
+
+// ReadNotification 将通知标记为已读
+// uuidList为空时将该用户的全部未读通知标记为已读，只会更新属于该用户的通知
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - int: 状态码，0表示成功，-1表示系统错误
+func (n *notificationService) ReadNotification(req request.ReadNotificationRequest) (string, int) {
+	query := dao.GormDB.Model(&model.Notification{}).Where("user_id = ? AND status = ?", req.OwnerId, notification_status_enum.UNREAD)
+	if len(req.UuidList) > 0 {
+		query = query.Where("uuid IN (?)", req.UuidList)
+	}
+	if res := query.Updates(map[string]interface{}{
+		"status":  notification_status_enum.READ,
+		"read_at": sql.NullTime{Time: time.Now(), Valid: true},
+	}); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	return "已标记为已读", 0
+}
+
+// toNotificationRespond 将通知记录转换为响应对象
+func toNotificationRespond(notification model.Notification) respond.NotificationRespond {
+	return respond.NotificationRespond{
+		Uuid:      notification.Uuid,
+		Type:      notification.Type,
+		Content:   notification.Content,
+		TargetId:  notification.TargetId,
+		Status:    notification.Status,
+		CreatedAt: notification.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// nicknameOf 获取用户昵称，用于拼接通知内容，查询失败时退化为用户ID
+func nicknameOf(userId string) string {
+	var user model.UserInfo
+	if res := dao.GormDB.Select("nickname").First(&user, "uuid = ?", userId); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return userId
+	}
+	return user.Nickname
+}
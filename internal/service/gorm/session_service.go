@@ -26,6 +26,9 @@ type sessionService struct {
 
 var SessionService = new(sessionService)
 
+// sessionListOrder 会话列表排序：归档的会话排在最后，置顶的会话按置顶时间排在最前，其余按创建时间倒序
+const sessionListOrder = "is_archived ASC, is_pinned DESC, pinned_at DESC, created_at DESC"
+
 // CreateSession 创建会话
 // 功能：创建一个新的会话记录，支持用户与用户之间或用户与群聊之间的会话
 // 参数：req - 包含创建会话所需信息的请求对象，包括发送者ID和接收者ID
@@ -173,9 +176,9 @@ func (s *sessionService) GetUserSessionList(ownerId string) (string, []respond.U
 	rspString, err := myredis.GetKeyNilIsErr("session_list_" + ownerId)
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			// 缓存中不存在，从数据库查询用户的所有会话记录，按创建时间倒序排列
+			// 缓存中不存在，从数据库查询用户的所有会话记录，置顶在前、归档在后，其余按创建时间倒序排列
 			var sessionList []model.Session
-			if res := dao.GormDB.Order("created_at DESC").Where("send_id = ?", ownerId).Find(&sessionList); res.Error != nil {
+			if res := dao.GormDB.Order(sessionListOrder).Where("send_id = ?", ownerId).Find(&sessionList); res.Error != nil {
 				if errors.Is(res.Error, gorm.ErrRecordNotFound) {
 					// 没有找到任何会话记录
 					zlog.Info("未创建用户会话")
@@ -194,10 +197,13 @@ func (s *sessionService) GetUserSessionList(ownerId string) (string, []respond.U
 				if session.ReceiveId[0] == 'U' {
 					// 创建用户会话响应对象，包含会话ID、头像、用户ID和用户名
 					sessionListRsp = append(sessionListRsp, respond.UserSessionListRespond{
-						SessionId: session.Uuid,        // 会话UUID
-						Avatar:    session.Avatar,      // 接收方头像
-						UserId:    session.ReceiveId,   // 接收方用户ID
-						Username:  session.ReceiveName, // 接收方用户名
+						SessionId:  session.Uuid,            // 会话UUID
+						Avatar:     session.Avatar,          // 接收方头像
+						UserId:     session.ReceiveId,       // 接收方用户ID
+						Username:   session.ReceiveName,     // 接收方用户名
+						IsMuted:    session.IsMuted == 1,    // 是否免打扰
+						IsPinned:   session.IsPinned == 1,   // 是否置顶
+						IsArchived: session.IsArchived == 1, // 是否归档
 					})
 				}
 			}
@@ -245,9 +251,9 @@ func (s *sessionService) GetGroupSessionList(ownerId string) (string, []respond.
 	rspString, err := myredis.GetKeyNilIsErr("group_session_list_" + ownerId)
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			// 缓存中不存在，从数据库查询用户的所有会话记录，按创建时间倒序排列
+			// 缓存中不存在，从数据库查询用户的所有会话记录，置顶在前、归档在后，其余按创建时间倒序排列
 			var sessionList []model.Session
-			if res := dao.GormDB.Order("created_at DESC").Where("send_id = ?", ownerId).Find(&sessionList); res.Error != nil {
+			if res := dao.GormDB.Order(sessionListOrder).Where("send_id = ?", ownerId).Find(&sessionList); res.Error != nil {
 				if errors.Is(res.Error, gorm.ErrRecordNotFound) {
 					// 没有找到任何会话记录
 					zlog.Info("未创建群聊会话")
@@ -266,10 +272,13 @@ func (s *sessionService) GetGroupSessionList(ownerId string) (string, []respond.
 				if session.ReceiveId[0] == 'G' {
 					// 创建群聊会话响应对象，包含会话ID、头像、群聊ID和群聊名称
 					sessionListRsp = append(sessionListRsp, respond.GroupSessionListRespond{
-						SessionId: session.Uuid,        // 会话UUID
-						Avatar:    session.Avatar,      // 接收方头像
-						GroupId:   session.ReceiveId,   // 接收方群聊ID
-						GroupName: session.ReceiveName, // 接收方群聊名称
+						SessionId:  session.Uuid,            // 会话UUID
+						Avatar:     session.Avatar,          // 接收方头像
+						GroupId:    session.ReceiveId,       // 接收方群聊ID
+						GroupName:  session.ReceiveName,     // 接收方群聊名称
+						IsMuted:    session.IsMuted == 1,    // 是否免打扰
+						IsPinned:   session.IsPinned == 1,   // 是否置顶
+						IsArchived: session.IsArchived == 1, // 是否归档
 					})
 				}
 			}
@@ -346,6 +355,70 @@ func (s *sessionService) DeleteSession(ownerId, sessionId string) (string, int)
 	return "删除成功", 0
 }
 
+// UpdateSessionSetting 更新会话的免打扰、置顶和归档设置
+// 功能：会话记录本身就属于单个用户，设置直接保存在会话上；置顶会取消归档，归档会取消置顶
+// 参数：req - 包含用户ID、会话ID以及需要修改的设置，未提供的设置保持不变
+// 返回值:
+//   - string: 操作结果消息，成功或失败的具体描述
+//   - int: 状态码，0表示成功，-1表示系统错误，-2表示会话不存在
+func (s *sessionService) UpdateSessionSetting(req request.UpdateSessionSettingRequest) (string, int) {
+	// 只能修改属于自己的会话
+	var session model.Session
+	if res := dao.GormDB.Where("uuid = ? AND send_id = ?", req.SessionId, req.OwnerId).First(&session); res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "会话不存在", -2
+		}
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+
+	updates := make(map[string]interface{})
+	if req.IsMuted != nil {
+		updates["is_muted"] = boolToInt8(*req.IsMuted)
+	}
+	if req.IsArchived != nil {
+		updates["is_archived"] = boolToInt8(*req.IsArchived)
+		if *req.IsArchived {
+			updates["is_pinned"] = 0
+			updates["pinned_at"] = nil
+		}
+	}
+	if req.IsPinned != nil {
+		updates["is_pinned"] = boolToInt8(*req.IsPinned)
+		if *req.IsPinned {
+			updates["pinned_at"] = time.Now()
+			updates["is_archived"] = 0
+		} else {
+			updates["pinned_at"] = nil
+		}
+	}
+	if len(updates) == 0 {
+		return "设置未修改", 0
+	}
+	if res := dao.GormDB.Model(&session).Updates(updates); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+
+	// 清除用户的会话列表缓存，确保下次获取时能反映最新的排序和设置
+	if err := myredis.DelKeysWithPattern("group_session_list_" + req.OwnerId); err != nil {
+		zlog.Error(err.Error())
+	}
+	if err := myredis.DelKeysWithPattern("session_list_" + req.OwnerId); err != nil {
+		zlog.Error(err.Error())
+	}
+
+	return "设置成功", 0
+}
+
+// boolToInt8 将布尔值转换为数据库中的0/1标记
+func boolToInt8(b bool) int8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 // CheckOpenSessionAllowed 检查是否允许发起会话
 // 功能：检查两个用户之间或用户与群聊之间是否允许建立会话，验证双方状态和关系
 // 参数：sendId - 发送者ID，receiveId - 接收者ID
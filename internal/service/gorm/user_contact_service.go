@@ -14,6 +14,7 @@ import (
 	"gochat/pkg/enum/contact/contact_type_enum"
 	"gochat/pkg/enum/contact_apply/contact_apply_status_enum"
 	"gochat/pkg/enum/group_info/group_status_enum"
+	"gochat/pkg/enum/notification/notification_type_enum"
 	"gochat/pkg/enum/user_info/user_status_enum"
 	"gochat/pkg/util/random"
 	"gochat/pkg/zlog"
@@ -473,6 +474,10 @@ func (u *userContactService) ApplyContact(req request.ApplyContactRequest) (stri
 			zlog.Error(err.Error())
 		}
 
+		// 通知被申请的用户
+		NotificationService.Notify(req.ContactId, notification_type_enum.CONTACT_APPLY,
+			fmt.Sprintf("%s申请添加你为好友：%s", nicknameOf(req.OwnerId), req.Message), req.OwnerId)
+
 		return "申请成功", 0
 	case 'G':
 		// 处理添加群聊的情况
@@ -494,6 +499,16 @@ func (u *userContactService) ApplyContact(req request.ApplyContactRequest) (stri
 			return "群聊已被禁用", -2
 		}
 
+		// 已经是群成员时无需申请
+		isMember, err := isGroupMember(req.ContactId, req.OwnerId)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+		if isMember {
+			return "你已是该群成员", -2
+		}
+
 		// 查找是否已有申请记录
 		var contactApply model.ContactApply
 		if res := dao.GormDB.Where("user_id = ? AND contact_id = ?", req.OwnerId, req.ContactId).First(&contactApply); res.Error != nil {
@@ -526,8 +541,14 @@ func (u *userContactService) ApplyContact(req request.ApplyContactRequest) (stri
 			}
 		}
 
-		// 更新申请记录的时间
+		// 被群聊拉黑的用户不能再申请
+		if contactApply.Status == contact_apply_status_enum.BLACK {
+			return "你已被禁止申请加入该群聊", -2
+		}
+
+		// 更新申请记录的时间和状态，被拒绝后可以重新申请
 		contactApply.LastApplyAt = time.Now()
+		contactApply.Status = contact_apply_status_enum.PENDING
 
 		// 保存更新后的申请记录
 		if res := dao.GormDB.Save(&contactApply); res.Error != nil {
@@ -540,6 +561,12 @@ func (u *userContactService) ApplyContact(req request.ApplyContactRequest) (stri
 			zlog.Error(err.Error())
 		}
 
+		// 通知群主和管理员处理加群申请
+		content := fmt.Sprintf("%s申请加入群聊%s：%s", nicknameOf(req.OwnerId), group.Name, req.Message)
+		for _, manager := range append([]string{group.OwnerId}, group.AdminList()...) {
+			NotificationService.Notify(manager, notification_type_enum.CONTACT_APPLY, content, req.ContactId)
+		}
+
 		return "申请成功", 0
 	default:
 		// 联系人ID格式不正确，既不是用户也不是群聊
@@ -714,6 +741,10 @@ func (u *userContactService) PassContactApply(ownerId string, contactId string)
 			zlog.Error(err.Error())
 		}
 
+		// 通知申请人好友申请已通过
+		NotificationService.Notify(contactId, notification_type_enum.CONTACT_PASS,
+			fmt.Sprintf("%s通过了你的好友申请", nicknameOf(ownerId)), ownerId)
+
 		return "已添加该联系人", 0
 	} else {
 		// 处理用户加入群聊的场景
@@ -781,6 +812,10 @@ func (u *userContactService) PassContactApply(ownerId string, contactId string)
 			zlog.Error(err.Error())
 		}
 
+		// 通知申请人加群申请已通过
+		NotificationService.Notify(contactId, notification_type_enum.CONTACT_PASS,
+			fmt.Sprintf("你已加入群聊%s", group.Name), ownerId)
+
 		return "已通过加群申请", 0
 	}
 }
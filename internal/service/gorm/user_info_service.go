@@ -8,9 +8,11 @@ import (
 	"gochat/internal/dto/request"
 	"gochat/internal/dto/respond"
 	"gochat/internal/model"
+	"gochat/internal/service/chat"
 	myredis "gochat/internal/service/redis"
 	"gochat/internal/service/sms"
 	"gochat/pkg/constants"
+	"gochat/pkg/enum/notification/notification_type_enum"
 	"gochat/pkg/enum/user_info/user_status_enum"
 	"gochat/pkg/util/random"
 	"gochat/pkg/zlog"
@@ -586,6 +588,12 @@ func (u *userInfoService) DisableUsers(uuidList []string) (string, int) {
 				return constants.SYSTEM_ERROR, -1
 			}
 		}
+
+		// 先写入通知，用户被解禁后可在通知列表中看到禁用记录
+		NotificationService.Notify(user.Uuid, notification_type_enum.ACCOUNT_DISABLED, "你的账号已被管理员禁用", "")
+
+		// 强制被禁用的用户下线，无论其连接在集群中的哪个节点
+		chat.KickUser(user.Uuid, "账号已被禁用，已强制下线")
 	}
 
 	// TODO: 在实际部署中，应取消下面的注释以清除Redis中的联系人列表缓存
@@ -714,6 +722,7 @@ func (u *userInfoService) DeleteUsers(uuidList []string) (string, int) {
 			}
 		}
 
+		chat.KickUser(user.Uuid, "账号已被删除，已强制下线")
 	}
 
 	// TODO: 在实际部署中，应取消下面的注释以清除Redis中的联系人列表缓存
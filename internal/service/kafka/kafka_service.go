@@ -1,20 +1,35 @@
 // kafka 包提供了与 Apache Kafka 消息队列系统的集成服务
-// 主要负责聊天消息的发送和接收，同时也预留了登录登出消息的处理接口
+// 主要负责聊天消息的发送和接收，以及集群内登录登出等会话事件的广播
 package kafka
 
 import (
+	"context"
+	"errors"
 	"gochat/internal/config"
 	"gochat/pkg/zlog"
+	"strconv"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 )
 
+// 死信消息头部字段，记录消息被转存的原因和原始位置
+const (
+	HeaderFailureReason   = "failure_reason"   // 失败原因
+	HeaderOriginTopic     = "origin_topic"     // 原始主题
+	HeaderOriginPartition = "origin_partition" // 原始分区
+	HeaderOriginOffset    = "origin_offset"    // 原始偏移量
+	HeaderFailedAt        = "failed_at"        // 转存时间
+)
+
 // kafkaService 结构体定义了 Kafka 服务的相关组件
 // 包含用于聊天消息发送的写入器、用于接收消息的读取器和用于创建主题的连接
 type kafkaService struct {
-	ChatWriter *kafka.Writer // 聊天消息写入器，用于发送聊天消息到 Kafka
-	ChatReader *kafka.Reader // 聊天消息读取器，用于从 Kafka 接收聊天消息
+	ChatWriter       *kafka.Writer // 聊天消息写入器，用于发送聊天消息到 Kafka
+	ChatReader       *kafka.Reader // 聊天消息读取器，用于从 Kafka 接收聊天消息
+	DeadLetterWriter *kafka.Writer // 死信写入器，用于转存无法处理的聊天消息
+	SessionWriter    *kafka.Writer // 会话事件写入器，发布上下线、强制下线和通知事件
+	SessionReader    *kafka.Reader // 会话事件读取器，每个节点独立消费
 }
 
 // KafkaService 全局唯一的 Kafka 服务实例
@@ -30,7 +45,23 @@ func (k *kafkaService) KafkaInit() {
 		Topic:                  kafkaConfig.ChatTopic,
 		Balancer:               &kafka.Hash{},
 		WriteTimeout:           kafkaConfig.Timeout * time.Second,
-		RequiredAcks:           kafka.RequireNone,
+		RequiredAcks:           requiredAcks(kafkaConfig.RequiredAcks),
+		MaxAttempts:            kafkaConfig.MaxAttempts,
+		WriteBackoffMin:        kafkaConfig.RetryBackoffMin * time.Millisecond,
+		WriteBackoffMax:        kafkaConfig.RetryBackoffMax * time.Millisecond,
+		AllowAutoTopicCreation: false,
+	}
+
+	// 死信写入器始终要求全部副本确认，避免转存的消息再次丢失
+	k.DeadLetterWriter = &kafka.Writer{
+		Addr:                   kafka.TCP(kafkaConfig.HostPort),
+		Topic:                  kafkaConfig.DeadLetterTopic,
+		Balancer:               &kafka.Hash{},
+		WriteTimeout:           kafkaConfig.Timeout * time.Second,
+		RequiredAcks:           kafka.RequireAll,
+		MaxAttempts:            kafkaConfig.MaxAttempts,
+		WriteBackoffMin:        kafkaConfig.RetryBackoffMin * time.Millisecond,
+		WriteBackoffMax:        kafkaConfig.RetryBackoffMax * time.Millisecond,
 		AllowAutoTopicCreation: false,
 	}
 
@@ -42,6 +73,18 @@ func (k *kafkaService) KafkaInit() {
 		GroupID:        "chat",
 		StartOffset:    kafka.LastOffset,
 	})
+
+	// 会话事件只关心时效，丢失后可由下一次上下线修正，只需leader确认
+	// 所有会话事件写入同一主题并以用户ID为键，同一用户的上线和下线事件落在同一分区，按发布顺序消费
+	k.SessionWriter = &kafka.Writer{
+		Addr:                   kafka.TCP(kafkaConfig.HostPort),
+		Topic:                  kafkaConfig.SessionTopic,
+		Balancer:               &kafka.Hash{},
+		WriteTimeout:           kafkaConfig.Timeout * time.Second,
+		RequiredAcks:           kafka.RequireOne,
+		AllowAutoTopicCreation: false,
+	}
+	k.SessionReader = newSessionEventReader()
 }
 
 // KafkaClose 关闭 Kafka 服务
@@ -57,10 +100,112 @@ func (k *kafkaService) KafkaClose() {
 			zlog.Error(err.Error())
 		}
 	}
+	if k.DeadLetterWriter != nil {
+		if err := k.DeadLetterWriter.Close(); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+	if k.SessionWriter != nil {
+		if err := k.SessionWriter.Close(); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+	if k.SessionReader != nil {
+		if err := k.SessionReader.Close(); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+}
+
+// requiredAcks 将配置中的确认级别转换为 kafka-go 的枚举值
+// 未配置或无法识别时默认要求全部副本确认
+func requiredAcks(acks string) kafka.RequiredAcks {
+	switch acks {
+	case "none":
+		return kafka.RequireNone
+	case "one":
+		return kafka.RequireOne
+	default:
+		return kafka.RequireAll
+	}
+}
+
+// SendToDeadLetter 将无法处理的消息连同失败原因转存到死信主题
+// 参数:
+//   - message: 从聊天主题读取到的原始消息
+//   - reason: 处理失败的原因
+func (k *kafkaService) SendToDeadLetter(ctx context.Context, message kafka.Message, reason string) error {
+	if k.DeadLetterWriter == nil {
+		return errors.New("死信写入器未初始化")
+	}
+	deadLetter := kafka.Message{
+		Key:   message.Key,
+		Value: message.Value,
+		Headers: []kafka.Header{
+			{Key: HeaderFailureReason, Value: []byte(reason)},
+			{Key: HeaderOriginTopic, Value: []byte(message.Topic)},
+			{Key: HeaderOriginPartition, Value: []byte(strconv.Itoa(message.Partition))},
+			{Key: HeaderOriginOffset, Value: []byte(strconv.FormatInt(message.Offset, 10))},
+			{Key: HeaderFailedAt, Value: []byte(time.Now().Format(time.RFC3339))},
+		},
+	}
+	return k.DeadLetterWriter.WriteMessages(ctx, deadLetter)
+}
+
+// ReplayDeadLetter 将死信主题中的消息重新投递回聊天主题
+// 逐条读取死信并写回聊天主题，写入成功后才提交偏移量，保证消息不会在重放过程中丢失
+// 参数:
+//   - limit: 最多重放的消息数量，小于等于0表示不限制
+//   - idle: 等待新死信的最长时间，超时视为死信已处理完毕
+//
+// 返回值:
+//   - int: 成功重放的消息数量
+//   - error: 错误信息，成功时为nil
+func (k *kafkaService) ReplayDeadLetter(ctx context.Context, limit int, idle time.Duration) (int, error) {
+	kafkaConfig := config.GetConfig().KafkaConfig
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     []string{kafkaConfig.HostPort},
+		Topic:       kafkaConfig.DeadLetterTopic,
+		GroupID:     "chat_dead_letter_replay",
+		StartOffset: kafka.FirstOffset,
+	})
+	defer reader.Close()
+
+	replayed := 0
+	for limit <= 0 || replayed < limit {
+		fetchCtx, cancel := context.WithTimeout(ctx, idle)
+		deadLetter, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				// 在等待时间内没有新的死信，重放结束
+				return replayed, nil
+			}
+			return replayed, err
+		}
+
+		for _, header := range deadLetter.Headers {
+			if header.Key == HeaderFailureReason {
+				zlog.Info("重放死信，失败原因：" + string(header.Value))
+			}
+		}
+
+		if err := k.ChatWriter.WriteMessages(ctx, kafka.Message{
+			Key:   deadLetter.Key,
+			Value: deadLetter.Value,
+		}); err != nil {
+			return replayed, err
+		}
+		if err := reader.CommitMessages(ctx, deadLetter); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
 }
 
 // CreateTopic 创建 Kafka 主题
-// 根据配置创建消息主题，支持聊天、登录和登出主题
+// 根据配置创建消息主题，支持聊天、死信和会话事件主题
 func (k *kafkaService) CreateTopic() {
 	// 如果已经有topic了，就不创建了
 	kafkaConfig := config.GetConfig().KafkaConfig
@@ -74,23 +219,23 @@ func (k *kafkaService) CreateTopic() {
 	defer conn.Close() // 确保连接被关闭
 
 	// 定义主题配置数组
-	// 创建聊天、登录和登出主题
+	// 创建聊天、死信和会话事件主题
 	topicConfigs := []kafka.TopicConfig{
 		{
 			Topic:             kafkaConfig.ChatTopic,
 			NumPartitions:     kafkaConfig.Partition,
 			ReplicationFactor: 1,
 		},
-		// {
-		// 	Topic:             kafkaConfig.LoginTopic,
-		// 	NumPartitions:     kafkaConfig.Partition,
-		// 	ReplicationFactor: 1,
-		// },
-		// {
-		// 	Topic:             kafkaConfig.LogoutTopic,
-		// 	NumPartitions:     kafkaConfig.Partition,
-		// 	ReplicationFactor: 1,
-		// },
+		{
+			Topic:             kafkaConfig.DeadLetterTopic,
+			NumPartitions:     kafkaConfig.Partition,
+			ReplicationFactor: 1,
+		},
+		{
+			Topic:             kafkaConfig.SessionTopic,
+			NumPartitions:     kafkaConfig.Partition,
+			ReplicationFactor: 1,
+		},
 	}
 
 	// 创建主题
@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gochat/internal/config"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// 会话事件类型
+const (
+	SessionEventConnect    = "connect"    // 用户在某个节点建立了WebSocket连接
+	SessionEventDisconnect = "disconnect" // 用户从某个节点断开了WebSocket连接
+	SessionEventKick       = "kick"       // 强制用户下线，持有该用户连接的节点都需要断开
+	SessionEventNotify     = "notify"     // 向用户推送系统通知、通话状态等服务端帧，持有该用户连接的节点负责下发
+)
+
+// SessionEvent 在集群内广播的会话事件
+// 所有事件写入同一个会话主题，以用户ID为键，同一用户的事件按发布顺序消费
+type SessionEvent struct {
+	Type    string          `json:"type"`
+	UserId  string          `json:"user_id"`
+	NodeId  string          `json:"node_id"`           // 产生事件的节点
+	ConnId  string          `json:"conn_id,omitempty"` // 产生事件的连接，仅connect和disconnect事件使用
+	Reason  string          `json:"reason"`            // 强制下线的原因，仅kick事件使用
+	Payload json.RawMessage `json:"payload,omitempty"` // 下发给客户端的帧，仅notify事件使用
+	At      int64           `json:"at"`                // 事件产生时间，毫秒时间戳
+}
+
+// NewSessionEvent 构建由当前节点产生的会话事件
+func NewSessionEvent(eventType string, userId string, reason string) SessionEvent {
+	return SessionEvent{
+		Type:   eventType,
+		UserId: userId,
+		NodeId: config.GetConfig().NodeId,
+		Reason: reason,
+		At:     time.Now().UnixMilli(),
+	}
+}
+
+// PublishSessionEvent 将会话事件发布到会话主题
+func (k *kafkaService) PublishSessionEvent(ctx context.Context, event SessionEvent) error {
+	return k.PublishSessionEvents(ctx, event)
+}
+
+// PublishSessionEvents 批量发布会话事件，用于节点关闭时一次性广播本节点所有连接的下线
+// 以用户ID作为消息键，保证同一用户的事件落在同一分区、按顺序消费
+func (k *kafkaService) PublishSessionEvents(ctx context.Context, events ...SessionEvent) error {
+	if k.SessionWriter == nil {
+		return errors.New("会话事件写入器未初始化")
+	}
+	messages := make([]kafka.Message, 0, len(events))
+	for _, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, kafka.Message{Key: []byte(event.UserId), Value: value})
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return k.SessionWriter.WriteMessages(ctx, messages...)
+}
+
+// newSessionEventReader 创建会话事件读取器
+// 每个节点使用独立的消费组，保证所有节点都能收到全部会话事件
+func newSessionEventReader() *kafka.Reader {
+	kafkaConfig := config.GetConfig().KafkaConfig
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        []string{kafkaConfig.HostPort},
+		Topic:          kafkaConfig.SessionTopic,
+		CommitInterval: kafkaConfig.Timeout * time.Second,
+		GroupID:        "session_event_" + config.GetConfig().NodeId,
+		StartOffset:    kafka.LastOffset,
+	})
+}
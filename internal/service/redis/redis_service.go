@@ -60,6 +60,21 @@ func SetKeyEx(key string, value string, timeout time.Duration) error {
 	return nil
 }
 
+/*
+ * SetKeyNX 仅当键不存在时设置带过期时间的键值对
+ * 参数:
+ *   - key: 键名
+ *   - value: 键值
+ *   - timeout: 过期时间
+ *
+ * 返回值:
+ *   - bool: 是否设置成功，键已存在时为false
+ *   - error: 错误信息，成功时为nil
+ */
+func SetKeyNX(key string, value string, timeout time.Duration) (bool, error) {
+	return redisClient.SetNX(ctx, key, value, timeout).Result()
+}
+
 /*
  * GetKey 获取指定键的值
  * 参数:
@@ -202,6 +217,134 @@ func DelKeyIfExists(key string) error {
 	return nil
 }
 
+// delIfValueScript 只有键的值与参数一致时才删除，保证只释放自己持有的键
+var delIfValueScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+/*
+ * DelKeyIfValue 仅当键的值等于value时删除
+ * 参数:
+ *   - key: 要删除的键名
+ *   - value: 期望的键值
+ *
+ * 返回值:
+ *   - error: 错误信息，成功时为nil，键不存在或值不一致时也返回nil
+ */
+func DelKeyIfValue(key string, value string) error {
+	return delIfValueScript.Run(ctx, redisClient, []string{key}, value).Err()
+}
+
+/*
+ * DelKeys 一次删除多个确定的键，不存在的键被忽略
+ * 参数:
+ *   - keys: 要删除的键名列表
+ *
+ * 返回值:
+ *   - error: 错误信息，成功时为nil
+ */
+func DelKeys(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return redisClient.Del(ctx, keys...).Err()
+}
+
+/*
+ * GetKeysWithPrefix 获取指定前缀的所有键名
+ * 参数:
+ *   - prefix: 键名前缀
+ *
+ * 返回值:
+ *   - []string: 找到的键名，没有匹配的键时为空
+ *   - error: 错误信息，成功时为nil
+ */
+func GetKeysWithPrefix(prefix string) ([]string, error) {
+	return redisClient.Keys(ctx, prefix+"*").Result()
+}
+
+/*
+ * SetHashField 设置哈希表中的字段，并刷新整个哈希表的过期时间
+ * 参数:
+ *   - key: 哈希表键名
+ *   - field: 字段名
+ *   - value: 字段值
+ *   - timeout: 哈希表的过期时间
+ *
+ * 返回值:
+ *   - error: 错误信息，成功时为nil
+ */
+func SetHashField(key string, field string, value string, timeout time.Duration) error {
+	pipe := redisClient.TxPipeline()
+	pipe.HSet(ctx, key, field, value)
+	pipe.Expire(ctx, key, timeout)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// hdelIfValueScript 只有哈希表字段的值与参数一致时才删除该字段
+var hdelIfValueScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], ARGV[1]) == ARGV[2] then
+	return redis.call("HDEL", KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+/*
+ * DelHashFieldIfValue 仅当哈希表字段的值等于value时删除该字段
+ * 参数:
+ *   - key: 哈希表键名
+ *   - field: 字段名
+ *   - value: 期望的字段值
+ *
+ * 返回值:
+ *   - error: 错误信息，成功时为nil，字段不存在或值不一致时也返回nil
+ */
+func DelHashFieldIfValue(key string, field string, value string) error {
+	return hdelIfValueScript.Run(ctx, redisClient, []string{key}, field, value).Err()
+}
+
+/*
+ * ReplaceHash 用fields整体替换哈希表的内容并设置过期时间，fields为空时删除哈希表
+ * 参数:
+ *   - key: 哈希表键名
+ *   - fields: 新的字段和值
+ *   - timeout: 哈希表的过期时间
+ *
+ * 返回值:
+ *   - error: 错误信息，成功时为nil
+ */
+func ReplaceHash(key string, fields map[string]string, timeout time.Duration) error {
+	pipe := redisClient.TxPipeline()
+	pipe.Del(ctx, key)
+	if len(fields) > 0 {
+		values := make([]interface{}, 0, len(fields)*2)
+		for field, value := range fields {
+			values = append(values, field, value)
+		}
+		pipe.HSet(ctx, key, values...)
+		pipe.Expire(ctx, key, timeout)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+/*
+ * GetHashAll 获取哈希表的所有字段和值
+ * 参数:
+ *   - key: 哈希表键名
+ *
+ * 返回值:
+ *   - map[string]string: 字段和值，哈希表不存在时为空
+ *   - error: 错误信息，成功时为nil
+ */
+func GetHashAll(key string) (map[string]string, error) {
+	return redisClient.HGetAll(ctx, key).Result()
+}
+
 /*
  * DelKeysWithPattern 根据模式删除多个键
  * 参数:
@@ -347,3 +490,43 @@ func DeleteAllRedisKeys() error {
 	}
 	return nil
 }
+
+/*
+ * PushListWithLimit 向列表尾部追加元素，并将列表裁剪为最新的limit个元素
+ * 参数:
+ *   - key: 列表键名
+ *   - value: 追加的元素
+ *   - limit: 列表最多保留的元素个数，超出时丢弃最早的元素
+ *   - timeout: 列表的过期时间，每次追加都会刷新
+ *
+ * 返回值:
+ *   - error: 错误信息，成功时为nil
+ */
+func PushListWithLimit(key string, value string, limit int64, timeout time.Duration) error {
+	pipe := redisClient.TxPipeline()
+	pipe.RPush(ctx, key, value)
+	pipe.LTrim(ctx, key, -limit, -1)
+	pipe.Expire(ctx, key, timeout)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+/*
+ * PopListBatch 从列表头部取出最多count个元素
+ * 参数:
+ *   - key: 列表键名
+ *   - count: 最多取出的元素个数
+ *
+ * 返回值:
+ *   - []string: 取出的元素，列表不存在时为空
+ *   - error: 错误信息，成功时为nil
+ */
+func PopListBatch(key string, count int64) ([]string, error) {
+	pipe := redisClient.TxPipeline()
+	values := pipe.LRange(ctx, key, 0, count-1)
+	pipe.LTrim(ctx, key, count, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return values.Val(), nil
+}
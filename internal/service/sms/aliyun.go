@@ -0,0 +1,59 @@
+package sms
+
+import (
+	"errors"
+	"gochat/internal/config"
+	"gochat/pkg/zlog"
+
+	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
+	dysmsapi20170525 "github.com/alibabacloud-go/dysmsapi-20170525/v4/client"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// aliyunSender 通过阿里云短信服务发送短信
+type aliyunSender struct {
+	client       *dysmsapi20170525.Client
+	signName     string // 短信签名名称
+	templateCode string // 短信模板CODE，模板中的验证码变量名为code
+}
+
+// newAliyunSender 使用AK&SK初始化阿里云短信服务客户端
+func newAliyunSender(conf config.AuthCodeConfig) (*aliyunSender, error) {
+	// 工程代码泄露可能会导致 AccessKey 泄露，并威胁账号下所有资源的安全性。
+	// 建议使用更安全的 STS 方式，更多鉴权访问方式请参见：https://help.aliyun.com/document_detail/378661.html。
+	clientConfig := &openapi.Config{
+		AccessKeyId:     tea.String(conf.AccessKeyID),
+		AccessKeySecret: tea.String(conf.AccessKeySecret),
+	}
+	// Endpoint 请参考 https://api.aliyun.com/product/Dysmsapi
+	clientConfig.Endpoint = tea.String("dysmsapi.aliyuncs.com")
+	client, err := dysmsapi20170525.NewClient(clientConfig)
+	if err != nil {
+		return nil, errors.New("阿里云短信服务客户端创建失败：" + err.Error())
+	}
+	return &aliyunSender{client: client, signName: conf.SignName, templateCode: conf.TemplateCode}, nil
+}
+
+func (a *aliyunSender) SendCode(telephone string, code string) error {
+	sendSmsRequest := &dysmsapi20170525.SendSmsRequest{
+		SignName:      tea.String(a.signName),
+		TemplateCode:  tea.String(a.templateCode),
+		PhoneNumbers:  tea.String(telephone),
+		TemplateParam: tea.String("{\"code\":\"" + code + "\"}"), // 短信模板变量，将验证码嵌入JSON格式
+	}
+	rsp, err := a.client.SendSmsWithOptions(sendSmsRequest, &util.RuntimeOptions{})
+	if err != nil {
+		return err
+	}
+	zlog.Info(*util.ToJSONString(rsp))
+	// 请求成功不代表短信发送成功，业务结果以返回的Code为准
+	if rsp.Body == nil || tea.StringValue(rsp.Body.Code) != "OK" {
+		var message string
+		if rsp.Body != nil {
+			message = tea.StringValue(rsp.Body.Code) + " " + tea.StringValue(rsp.Body.Message)
+		}
+		return errors.New("阿里云短信发送失败：" + message)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package sms
+
+import (
+	"fmt"
+	"gochat/pkg/zlog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// logSender 不发送短信，把验证码写入日志，配置了文件路径时还追加到文件中，便于测试脚本读取
+type logSender struct {
+	path string
+	mu   sync.Mutex
+}
+
+func newLogSender(path string) *logSender {
+	return &logSender{path: path}
+}
+
+func (l *logSender) SendCode(telephone string, code string) error {
+	zlog.Info(fmt.Sprintf("短信验证码 %s：%s", telephone, code))
+	if l.path == "" {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// 每行一条记录：发送时间 手机号 验证码
+	_, err = fmt.Fprintf(file, "%s %s %s\n", time.Now().Format("2006-01-02 15:04:05"), telephone, code)
+	return err
+}
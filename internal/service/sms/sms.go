@@ -0,0 +1,43 @@
+// Package sms 提供短信验证码服务
+// 短信通过SmsSender发送，按authCodeConfig中的驱动选择阿里云短信服务或开发测试用的日志驱动
+package sms
+
+import (
+	"gochat/internal/config"
+	"gochat/pkg/zlog"
+)
+
+// 短信驱动
+const (
+	DriverAliyun = "aliyun" // 通过阿里云短信服务发送
+	DriverLog    = "log"    // 不发送短信，只把验证码写入日志和文件，用于开发和测试
+)
+
+// SmsSender 短信发送接口
+type SmsSender interface {
+	// SendCode 向手机号发送验证码短信
+	SendCode(telephone string, code string) error
+}
+
+// Sender 按配置创建的全局短信发送实例
+var Sender SmsSender
+
+/*
+ * init 初始化函数，在包被导入时自动执行
+ * 根据authCodeConfig中的驱动创建短信发送实例，未配置驱动时使用阿里云
+ */
+func init() {
+	conf := config.GetConfig().AuthCodeConfig
+	switch conf.Driver {
+	case DriverAliyun, "":
+		sender, err := newAliyunSender(conf)
+		if err != nil {
+			zlog.Fatal(err.Error())
+		}
+		Sender = sender
+	case DriverLog:
+		Sender = newLogSender(conf.CodeLogPath)
+	default:
+		zlog.Fatal("未知的短信驱动：" + conf.Driver)
+	}
+}
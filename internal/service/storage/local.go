@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gochat/pkg/zlog"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localStorage 本地磁盘存储，key的第一级目录映射到配置的静态目录
+type localStorage struct {
+	dirs map[string]string // key为第一级目录，value为对应的本地目录
+}
+
+func newLocalStorage(dirs map[string]string) *localStorage {
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+	return &localStorage{dirs: dirs}
+}
+
+// path 将key转换为本地文件路径
+func (l *localStorage) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("非法的对象路径：%s", key)
+	}
+	parts := strings.SplitN(key, "/", 2)
+	dir, ok := l.dirs[parts[0]]
+	if !ok || dir == "" {
+		return "", fmt.Errorf("未知的对象目录：%s", parts[0])
+	}
+	return filepath.Join(dir, parts[1]), nil
+}
+
+// Put 先写入同目录下的临时文件再改名，其他请求不会读到写了一半的文件
+func (l *localStorage) Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, reader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *localStorage) Exists(ctx context.Context, key string) (bool, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *localStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (l *localStorage) Delete(ctx context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// DownloadURL 本地文件通过静态路由访问
+func (l *localStorage) DownloadURL(ctx context.Context, key string) (string, error) {
+	return StaticURL(key), nil
+}
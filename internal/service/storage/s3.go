@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"gochat/internal/config"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3Storage S3兼容的对象存储，所有对象保存在同一个桶中，key直接作为对象名
+type s3Storage struct {
+	client        *minio.Client
+	bucket        string
+	presignExpire time.Duration
+}
+
+// newS3Storage 连接对象存储，桶不存在时自动创建
+func newS3Storage(conf config.StorageConfig) (*s3Storage, error) {
+	client, err := minio.New(conf.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(conf.AccessKeyID, conf.SecretAccessKey, ""),
+		Secure: conf.UseSSL,
+		Region: conf.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, conf.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, conf.Bucket, minio.MakeBucketOptions{Region: conf.Region}); err != nil {
+			return nil, err
+		}
+	}
+	return &s3Storage{
+		client:        client,
+		bucket:        conf.Bucket,
+		presignExpire: conf.PresignExpire * time.Minute,
+	}, nil
+}
+
+func (s *s3Storage) Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, reader, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *s3Storage) Exists(ctx context.Context, key string) (bool, error) {
+	if _, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{}); err != nil {
+		if isNoSuchKey(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *s3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject不会立即发起请求，先Stat一次以便对象不存在时返回ErrNotFound
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if isNoSuchKey(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return object, nil
+}
+
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+// DownloadURL 生成有时效的预签名下载地址，客户端直接从对象存储下载，不经过应用节点
+func (s *s3Storage) DownloadURL(ctx context.Context, key string) (string, error) {
+	presigned, err := s.client.PresignedGetObject(ctx, s.bucket, key, s.presignExpire, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	return presigned.String(), nil
+}
+
+// isNoSuchKey 判断错误是否表示对象不存在
+func isNoSuchKey(err error) bool {
+	return minio.ToErrorResponse(err).Code == "NoSuchKey"
+}
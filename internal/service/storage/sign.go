@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"gochat/internal/config"
+	"gochat/pkg/zlog"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DownloadPath 签名下载地址的路径
+const DownloadPath = "/file/download"
+
+// defaultSignExpire 未配置或配置为0时签名下载地址的有效期，单位分钟
+const defaultSignExpire = 10
+
+var (
+	ErrSignatureInvalid = errors.New("下载地址签名无效")
+	ErrSignatureExpired = errors.New("下载地址已过期")
+)
+
+var (
+	signKeyOnce sync.Once
+	signKey     []byte
+)
+
+// getSignKey 获取签名密钥
+// 未配置时生成随机密钥，只在本节点有效且重启后失效，多节点部署时必须配置相同的signSecret
+func getSignKey() []byte {
+	signKeyOnce.Do(func() {
+		if secret := config.GetConfig().StorageConfig.SignSecret; secret != "" {
+			signKey = []byte(secret)
+			return
+		}
+		zlog.Warn("未配置storageConfig.signSecret，使用随机密钥签名下载地址，多节点部署时下载地址无法跨节点使用")
+		signKey = make([]byte, 32)
+		if _, err := rand.Read(signKey); err != nil {
+			zlog.Fatal(err.Error())
+		}
+	})
+	return signKey
+}
+
+// sign 计算key、用户ID和过期时间的HMAC-SHA256签名
+func sign(key string, userId string, expires int64) string {
+	mac := hmac.New(sha256.New, getSignKey())
+	mac.Write([]byte(key + "\n" + userId + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signExpire 签名下载地址的有效期
+func signExpire() time.Duration {
+	if expire := config.GetConfig().StorageConfig.SignExpire; expire > 0 {
+		return expire * time.Minute
+	}
+	return defaultSignExpire * time.Minute
+}
+
+// SignDownloadURL 为用户生成有时效的签名下载地址，可以直接放在<img>、<a>标签中使用
+func SignDownloadURL(key string, userId string) string {
+	expires := time.Now().Add(signExpire()).Unix()
+	query := url.Values{}
+	query.Set("key", key)
+	query.Set("uid", userId)
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("sig", sign(key, userId, expires))
+	return DownloadPath + "?" + query.Encode()
+}
+
+// SignStaticURL 将固定访问路径转换为签名下载地址，不是固定访问路径或为空时原样返回
+// 消息和缓存中保存固定访问路径，返回给客户端时再签名，避免保存的地址过期
+func SignStaticURL(staticUrl string, userId string) string {
+	key, ok := KeyFromURL(staticUrl)
+	if !ok || IsPublic(key) {
+		return staticUrl
+	}
+	return SignDownloadURL(key, userId)
+}
+
+// VerifyDownloadURL 校验签名下载地址的参数，返回地址中的对象key
+func VerifyDownloadURL(query url.Values) (string, error) {
+	key := query.Get("key")
+	if !validKey(key) {
+		return "", ErrSignatureInvalid
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return "", ErrSignatureInvalid
+	}
+	expected := sign(key, query.Get("uid"), expires)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("sig"))) {
+		return "", ErrSignatureInvalid
+	}
+	if time.Now().Unix() > expires {
+		return "", ErrSignatureExpired
+	}
+	return key, nil
+}
+
+// LocalPath 使用本地驱动时返回对象的本地文件路径，其他驱动返回false
+func LocalPath(key string) (string, bool) {
+	local, ok := Default.(*localStorage)
+	if !ok {
+		return "", false
+	}
+	path, err := local.path(key)
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
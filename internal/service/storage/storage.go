@@ -0,0 +1,129 @@
+// Package storage 提供上传文件的对象存储服务
+// 支持本地磁盘和S3兼容的对象存储，多节点部署时使用S3驱动共享上传的文件
+package storage
+
+import (
+	"context"
+	"errors"
+	"gochat/internal/config"
+	"gochat/pkg/zlog"
+	"io"
+	"strings"
+)
+
+// 存储驱动
+const (
+	DriverLocal = "local" // 保存在本节点的静态目录下
+	DriverS3    = "s3"    // 保存在S3兼容的对象存储中，如MinIO
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("对象不存在")
+
+// Storage 对象存储接口
+// key为对象在存储中的路径，形如files/<哈希>.<扩展名>，第一级目录表示文件用途
+type Storage interface {
+	// Put 保存对象，已存在时覆盖
+	Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error
+	// Exists 判断对象是否存在
+	Exists(ctx context.Context, key string) (bool, error)
+	// Open 打开对象用于读取，对象不存在时返回ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// DownloadURL 返回客户端可以直接下载对象的地址
+	// 本地驱动返回静态路径，S3驱动返回有时效的预签名地址
+	DownloadURL(ctx context.Context, key string) (string, error)
+}
+
+// StaticPrefix 对象的固定访问路径前缀，保存到消息中的都是固定路径，不受预签名地址过期的影响
+const StaticPrefix = "/static/"
+
+// Default 按配置创建的全局存储实例
+var Default Storage
+
+// bundled 本地静态目录，保存随程序发布的默认头像等文件，使用S3驱动时也从这里提供
+var bundled *localStorage
+
+/*
+ * init 初始化函数，在包被导入时自动执行
+ * 根据storageConfig中的驱动创建存储实例
+ */
+func init() {
+	conf := config.GetConfig()
+	bundled = newLocalStorage(map[string]string{
+		"avatars": conf.StaticAvatarPath,
+		"files":   conf.StaticFilePath,
+		"thumbs":  conf.StaticThumbPath,
+		"parts":   conf.StorageConfig.PartPath,
+	})
+	switch conf.StorageConfig.Driver {
+	case DriverS3:
+		s3, err := newS3Storage(conf.StorageConfig)
+		if err != nil {
+			zlog.Fatal(err.Error())
+		}
+		Default = s3
+	case DriverLocal, "":
+		Default = bundled
+	default:
+		zlog.Fatal("未知的存储驱动：" + conf.StorageConfig.Driver)
+	}
+}
+
+// BundledPath 返回本地静态目录中对应key的文件路径，文件不存在时返回false
+func BundledPath(key string) (string, bool) {
+	path, err := bundled.path(key)
+	if err != nil {
+		return "", false
+	}
+	if exists, err := bundled.Exists(context.Background(), key); err != nil || !exists {
+		return "", false
+	}
+	return path, true
+}
+
+// publicDirs 可以公开访问的目录，头像通过固定访问路径直接访问，聊天文件只能通过签名下载地址访问
+var publicDirs = map[string]bool{
+	"avatars": true,
+}
+
+// IsPublic 判断对象是否可以不经鉴权直接访问
+func IsPublic(key string) bool {
+	return publicDirs[strings.SplitN(key, "/", 2)[0]]
+}
+
+// StaticURL 返回对象的固定访问路径
+func StaticURL(key string) string {
+	return StaticPrefix + key
+}
+
+// KeyFromURL 从固定访问路径中解析对象的key，不是固定访问路径时返回false
+func KeyFromURL(url string) (string, bool) {
+	// 兼容带有协议和主机前缀的地址
+	if i := strings.Index(url, StaticPrefix); i >= 0 {
+		url = url[i:]
+	}
+	if !strings.HasPrefix(url, StaticPrefix) {
+		return "", false
+	}
+	key := strings.TrimPrefix(url, StaticPrefix)
+	if !validKey(key) {
+		return "", false
+	}
+	return key, true
+}
+
+// validKey 校验key只包含一级目录和文件名，且不能跳出目录
+func validKey(key string) bool {
+	parts := strings.Split(key, "/")
+	if len(parts) != 2 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, "\\\x00") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,12 @@
+// call_status_enum 包定义了音视频通话的状态
+// 振铃和已接听为进行中的状态，其余为结束状态，结束后不能再变更
+package call_status_enum
+
+const (
+	RINGING  = iota // 振铃中，等待被叫接听
+	ACCEPTED        // 被叫已接听，通话中
+	REJECTED        // 被叫拒绝
+	MISSED          // 未接听，包括振铃超时、主叫在接听前挂断和被叫不在线
+	ENDED           // 接听后任意一方挂断
+	BUSY            // 被叫正在通话中
+)
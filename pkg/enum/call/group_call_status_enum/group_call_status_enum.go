@@ -0,0 +1,8 @@
+// group_call_status_enum 包定义了群通话房间的状态
+// 群通话没有振铃和接听，成员随时加入和离开，最后一个参与者离开时房间结束
+package group_call_status_enum
+
+const (
+	ACTIVE = iota // 进行中，群成员可以加入
+	ENDED         // 已结束
+)
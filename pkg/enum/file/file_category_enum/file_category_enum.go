@@ -0,0 +1,8 @@
+// file_category_enum 包定义了上传文件的用途分类
+// 不同分类的文件保存在不同的目录下，通过不同的静态路径访问
+package file_category_enum
+
+const (
+	FILE   = iota // 聊天中发送的文件
+	AVATAR        // 用户或群聊头像
+)
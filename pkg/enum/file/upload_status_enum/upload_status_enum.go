@@ -0,0 +1,8 @@
+// upload_status_enum 包定义了分片上传会话的状态
+package upload_status_enum
+
+const (
+	UPLOADING = iota // 上传中，可以继续上传分片
+	COMPLETED        // 已完成合并
+	ABORTED          // 已取消或已过期，分片已被清理
+)
@@ -0,0 +1,8 @@
+// group_invite_status_enum 包定义了群聊邀请链接的状态
+// 过期和用完次数的邀请链接不单独记录状态，使用时根据过期时间和使用次数判断
+package group_invite_status_enum
+
+const (
+	VALID   = iota // 有效
+	REVOKED        // 已被群主或管理员撤销
+)
@@ -0,0 +1,25 @@
+// frame_error_enum 包定义了通过WebSocket回送给发送者的错误帧错误码
+// 前端根据错误码决定提示内容以及是否重试
+package frame_error_enum
+
+const (
+	SERVER_BUSY   = "server_busy"   // 服务器转发通道繁忙，消息未被接收，可稍后重试
+	SEND_FAILED   = "send_failed"   // 消息投递到消息队列失败，可稍后重试
+	SLOW_CONSUMER = "slow_consumer" // 客户端接收过慢，连接被服务器断开
+	KICKED        = "kicked"        // 账号被禁用或删除，连接被强制断开
+	REPLACED      = "replaced"      // 同一账号建立了新连接，旧连接被断开
+	GOING_AWAY    = "going_away"    // 服务器正在关闭，客户端需要重新连接
+
+	// 以下错误码表示消息未通过校验被拒绝，重试也不会成功
+	INVALID_MESSAGE  = "invalid_message"  // 消息格式错误，无法解析或缺少必要字段
+	CONTENT_TOO_LONG = "content_too_long" // 消息内容超过长度限制
+	SENDER_DISABLED  = "sender_disabled"  // 发送者账号已被禁用
+	NOT_CONTACT      = "not_contact"      // 接收者不是发送者的好友
+	BLOCKED          = "blocked"          // 发送者与接收者之间存在拉黑关系
+	NOT_GROUP_MEMBER = "not_group_member" // 发送者不是群成员
+	MUTED            = "muted"            // 发送者在群内被禁言
+	GROUP_DISABLED   = "group_disabled"   // 群聊已被禁用或解散
+	CALL_BUSY        = "call_busy"        // 发送者正在通话中，不能发起新的通话
+	CALL_INVALID     = "call_invalid"     // 通话不存在、已结束或当前状态不允许该操作
+	CALL_FULL        = "call_full"        // 群通话人数已满
+)
@@ -0,0 +1,7 @@
+// notification_status_enum 包定义了系统通知的阅读状态
+package notification_status_enum
+
+const (
+	UNREAD = iota // 未读
+	READ          // 已读
+)
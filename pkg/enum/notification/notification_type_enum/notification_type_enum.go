@@ -0,0 +1,17 @@
+// notification_type_enum 包定义了系统通知的类型
+// 前端根据类型决定通知的展示方式以及点击后的跳转
+package notification_type_enum
+
+const (
+	CONTACT_APPLY    = iota // 收到好友申请或加群申请，发给被申请的用户或群主
+	CONTACT_PASS            // 好友申请或加群申请被通过，发给申请人
+	GROUP_KICKED            // 被移出群聊
+	GROUP_DISMISSED         // 所在群聊被解散
+	ACCOUNT_DISABLED        // 账号被管理员禁用
+	GROUP_MUTED             // 在群内被禁言
+	GROUP_UNMUTED           // 在群内被解除禁言
+	GROUP_MUTE_ALL          // 群聊开启或关闭全员禁言
+	GROUP_ADMIN             // 被设置或取消群管理员
+	CONTACT_REFUSE          // 加群申请被拒绝，发给申请人
+	GROUP_INVITED           // 被群成员邀请加入群聊，或邀请已提交群主和管理员审核
+)
@@ -0,0 +1,153 @@
+package media
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrUnsupportedAudio 不支持的音频格式或无法解析
+var ErrUnsupportedAudio = errors.New("无法解析音频时长")
+
+// AudioDuration 根据音频格式计算时长，format为扩展名，支持wav和mp3，size为文件大小
+func AudioDuration(reader io.Reader, size int64, format string) (time.Duration, error) {
+	switch format {
+	case "wav":
+		return wavDuration(reader, size)
+	case "mp3":
+		return mp3Duration(reader)
+	default:
+		return 0, ErrUnsupportedAudio
+	}
+}
+
+// wavDuration 读取RIFF文件的fmt块和data块，时长 = 数据字节数 / 每秒字节数
+// 块大小来自文件内容，超过文件剩余大小的块视为无法解析，不按块大小分配内存
+func wavDuration(reader io.Reader, size int64) (time.Duration, error) {
+	r := bufio.NewReader(reader)
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return 0, ErrUnsupportedAudio
+	}
+
+	offset := int64(len(header))
+	var byteRate uint32
+	chunkHeader := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, chunkHeader); err != nil {
+			return 0, ErrUnsupportedAudio
+		}
+		offset += int64(len(chunkHeader))
+		id := string(chunkHeader[0:4])
+		chunkSize := int64(binary.LittleEndian.Uint32(chunkHeader[4:8]))
+		if chunkSize > size-offset {
+			return 0, ErrUnsupportedAudio
+		}
+		switch id {
+		case "fmt ":
+			if chunkSize < 16 {
+				return 0, ErrUnsupportedAudio
+			}
+			// 只需要PCM格式的前16字节，扩展字段直接跳过
+			format := make([]byte, 16)
+			if _, err := io.ReadFull(r, format); err != nil {
+				return 0, err
+			}
+			byteRate = binary.LittleEndian.Uint32(format[8:12])
+			if err := discard(r, chunkSize-16+chunkSize%2); err != nil {
+				return 0, err
+			}
+		case "data":
+			if byteRate == 0 {
+				return 0, ErrUnsupportedAudio
+			}
+			return time.Duration(uint64(chunkSize) * uint64(time.Second) / uint64(byteRate)), nil
+		default:
+			// 块大小为奇数时后面有一个填充字节
+			if err := discard(r, chunkSize+chunkSize%2); err != nil {
+				return 0, err
+			}
+		}
+		offset += chunkSize + chunkSize%2
+	}
+}
+
+// discard 跳过n个字节，使用int64计数，不受int位数的限制
+func discard(r io.Reader, n int64) error {
+	if _, err := io.CopyN(io.Discard, r, n); err != nil {
+		return ErrUnsupportedAudio
+	}
+	return nil
+}
+
+// mp3比特率表（kbps），下标为帧头中的比特率索引，分别对应MPEG1 Layer III和MPEG2/2.5 Layer III
+var (
+	mp3BitratesV1 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mp3BitratesV2 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	// 采样率表，第一维为版本：0.MPEG2.5，2.MPEG2，3.MPEG1
+	mp3SampleRates = [4][3]int{{11025, 12000, 8000}, {}, {22050, 24000, 16000}, {44100, 48000, 32000}}
+)
+
+// mp3Duration 跳过ID3v2标签后逐帧累加采样数，可同时处理固定比特率和可变比特率的文件
+func mp3Duration(reader io.Reader) (time.Duration, error) {
+	r := bufio.NewReader(reader)
+	if id3, err := r.Peek(10); err == nil && string(id3[0:3]) == "ID3" {
+		// 标签大小使用7位有效的同步安全整数
+		size := int(id3[6])<<21 | int(id3[7])<<14 | int(id3[8])<<7 | int(id3[9])
+		if _, err := r.Discard(10 + size); err != nil {
+			return 0, err
+		}
+	}
+
+	var total time.Duration
+	frames := 0
+	header := make([]byte, 4)
+	for {
+		peek, err := r.Peek(4)
+		if err != nil {
+			break
+		}
+		copy(header, peek)
+		// 帧同步：11位全1，且只处理Layer III
+		if header[0] != 0xff || header[1]&0xe0 != 0xe0 || (header[1]>>1)&0x03 != 0x01 {
+			if _, err := r.Discard(1); err != nil {
+				break
+			}
+			continue
+		}
+		version := (header[1] >> 3) & 0x03
+		bitrateIndex := header[2] >> 4
+		sampleRateIndex := (header[2] >> 2) & 0x03
+		padding := int((header[2] >> 1) & 0x01)
+		if version == 1 || sampleRateIndex == 3 || bitrateIndex == 0 || bitrateIndex == 15 {
+			if _, err := r.Discard(1); err != nil {
+				break
+			}
+			continue
+		}
+
+		sampleRate := mp3SampleRates[version][sampleRateIndex]
+		var frameLength, samples int
+		if version == 3 {
+			frameLength = 144*mp3BitratesV1[bitrateIndex]*1000/sampleRate + padding
+			samples = 1152
+		} else {
+			frameLength = 72*mp3BitratesV2[bitrateIndex]*1000/sampleRate + padding
+			samples = 576
+		}
+		if _, err := r.Discard(frameLength); err != nil {
+			break
+		}
+		total += time.Duration(samples) * time.Second / time.Duration(sampleRate)
+		frames++
+	}
+	if frames == 0 {
+		return 0, ErrUnsupportedAudio
+	}
+	return total, nil
+}
@@ -0,0 +1,144 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// wavChunk 生成一个RIFF块，块大小为奇数时补一个填充字节
+func wavChunk(id string, size uint32, data []byte) []byte {
+	chunk := make([]byte, 8, 8+len(data)+1)
+	copy(chunk, id)
+	binary.LittleEndian.PutUint32(chunk[4:8], size)
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// pcmFormat 生成fmt块的内容，extra为扩展字段的长度
+func pcmFormat(sampleRate uint32, channels uint16, bitsPerSample uint16, extra int) []byte {
+	format := make([]byte, 16+extra)
+	binary.LittleEndian.PutUint16(format[0:2], 1)
+	binary.LittleEndian.PutUint16(format[2:4], channels)
+	binary.LittleEndian.PutUint32(format[4:8], sampleRate)
+	blockAlign := channels * bitsPerSample / 8
+	binary.LittleEndian.PutUint32(format[8:12], sampleRate*uint32(blockAlign))
+	binary.LittleEndian.PutUint16(format[12:14], blockAlign)
+	binary.LittleEndian.PutUint16(format[14:16], bitsPerSample)
+	return format
+}
+
+func wavFile(chunks ...[]byte) []byte {
+	body := []byte("WAVE")
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+	file := make([]byte, 8, 8+len(body))
+	copy(file, "RIFF")
+	binary.LittleEndian.PutUint32(file[4:8], uint32(len(body)))
+	return append(file, body...)
+}
+
+func TestWavDuration(t *testing.T) {
+	format := pcmFormat(8000, 1, 16, 0)
+	// 8000Hz单声道16位，每秒16000字节
+	data := make([]byte, 24000)
+	tests := []struct {
+		name string
+		file []byte
+		want time.Duration
+	}{
+		{
+			name: "pcm",
+			file: wavFile(wavChunk("fmt ", 16, format), wavChunk("data", uint32(len(data)), data)),
+			want: 1500 * time.Millisecond,
+		},
+		{
+			name: "extensible fmt and odd-sized chunk",
+			file: wavFile(
+				wavChunk("fmt ", 18, pcmFormat(8000, 1, 16, 2)),
+				wavChunk("LIST", 3, []byte("abc")),
+				wavChunk("data", uint32(len(data)), data),
+			),
+			want: 1500 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := wavDuration(bytes.NewReader(tt.file), int64(len(tt.file)))
+			if err != nil {
+				t.Fatalf("wavDuration() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("wavDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWavDurationRejectsOversizedChunk(t *testing.T) {
+	format := pcmFormat(8000, 1, 16, 0)
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{name: "fmt", file: wavFile(wavChunk("fmt ", 0xffffffff, format))},
+		{name: "unknown", file: wavFile(wavChunk("fmt ", 16, format), wavChunk("LIST", 0xffffffff, nil))},
+		{name: "data", file: wavFile(wavChunk("fmt ", 16, format), wavChunk("data", 0xfffffff0, make([]byte, 16)))},
+		{name: "not riff", file: []byte("RIFX\x00\x00\x00\x00WAVE")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := wavDuration(bytes.NewReader(tt.file), int64(len(tt.file))); !errors.Is(err, ErrUnsupportedAudio) {
+				t.Errorf("wavDuration() error = %v, want %v", err, ErrUnsupportedAudio)
+			}
+		})
+	}
+}
+
+// mp3Frames 生成n个MPEG1 Layer III、128kbps、44100Hz的帧，每帧417字节
+func mp3Frames(n int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+	var file []byte
+	for i := 0; i < n; i++ {
+		file = append(file, frame...)
+	}
+	return file
+}
+
+func TestMp3Duration(t *testing.T) {
+	frameDuration := 1152 * time.Second / 44100
+	id3 := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x0a"), make([]byte, 10)...)
+	tests := []struct {
+		name string
+		file []byte
+		want time.Duration
+	}{
+		{name: "frames", file: mp3Frames(100), want: 100 * frameDuration},
+		{name: "id3 tag", file: append(id3, mp3Frames(10)...), want: 10 * frameDuration},
+		{name: "leading garbage", file: append([]byte{0x00, 0x12, 0xff}, mp3Frames(5)...), want: 5 * frameDuration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mp3Duration(bytes.NewReader(tt.file))
+			if err != nil {
+				t.Fatalf("mp3Duration() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("mp3Duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMp3DurationNoFrames(t *testing.T) {
+	if _, err := mp3Duration(bytes.NewReader(make([]byte, 1024))); !errors.Is(err, ErrUnsupportedAudio) {
+		t.Errorf("mp3Duration() error = %v, want %v", err, ErrUnsupportedAudio)
+	}
+}
@@ -0,0 +1,151 @@
+// Package media 提供上传文件的媒体处理，包括图片尺寸、缩略图、头像裁剪和音频时长
+// 只依赖标准库，图片支持jpeg、png、gif，音频支持wav和mp3
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // 注册gif解码器
+	"image/jpeg"
+	_ "image/png" // 注册png解码器
+	"io"
+)
+
+// maxImagePixels 允许解码的最大像素数，防止构造的超大尺寸图片耗尽内存
+const maxImagePixels = 50000000
+
+// ErrImageTooLarge 图片像素数超过限制
+var ErrImageTooLarge = errors.New("图片尺寸过大")
+
+// ImageSize 读取图片头部获取宽高，不解码像素
+func ImageSize(reader io.Reader) (int, int, error) {
+	config, _, err := image.DecodeConfig(reader)
+	if err != nil {
+		return 0, 0, err
+	}
+	return config.Width, config.Height, nil
+}
+
+// Thumbnail 生成长边不超过maxSide的jpeg缩略图，原图不超过maxSide时只转码不缩放
+// 动图只取第一帧，透明部分填充为白色
+func Thumbnail(reader io.ReadSeeker, maxSide int) ([]byte, error) {
+	src, err := decodeImage(reader)
+	if err != nil {
+		return nil, err
+	}
+	thumbWidth, thumbHeight := fitSize(src.Bounds().Dx(), src.Bounds().Dy(), maxSide)
+	dst := newWhiteImage(thumbWidth, thumbHeight)
+	scaleDown(dst, src, src.Bounds())
+	return encodeJPEG(dst)
+}
+
+// SquareImages 将图片居中裁剪为正方形，再分别缩放为sizes中的各个边长，按sizes的顺序返回jpeg数据
+// 用于头像，小于目标边长的图片会被放大
+func SquareImages(reader io.ReadSeeker, sizes []int) ([][]byte, error) {
+	src, err := decodeImage(reader)
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+	square := image.Rect(x0, y0, x0+side, y0+side)
+
+	images := make([][]byte, 0, len(sizes))
+	for _, size := range sizes {
+		dst := newWhiteImage(size, size)
+		scaleDown(dst, src, square)
+		data, err := encodeJPEG(dst)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, data)
+	}
+	return images, nil
+}
+
+// decodeImage 校验像素数后解码图片，reader需要位于图片开头
+func decodeImage(reader io.ReadSeeker) (image.Image, error) {
+	width, height, err := ImageSize(reader)
+	if err != nil {
+		return nil, err
+	}
+	if width*height > maxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(reader)
+	return src, err
+}
+
+// newWhiteImage 创建白色背景的画布
+func newWhiteImage(width int, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	return dst
+}
+
+// encodeJPEG 将图片编码为jpeg
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fitSize 按比例计算长边不超过maxSide的尺寸，短边至少为1
+func fitSize(width int, height int, maxSide int) (int, int) {
+	if width <= maxSide && height <= maxSide {
+		return width, height
+	}
+	if width >= height {
+		return maxSide, max(1, height*maxSide/width)
+	}
+	return max(1, width*maxSide/height), maxSide
+}
+
+// scaleDown 使用区域平均将src中srcBounds范围的部分缩小绘制到dst，并按alpha与dst上的白色背景混合
+// 缩略图只会缩小，区域平均比最近邻采样更平滑，也不需要引入额外的图像处理依赖；放大时退化为最近邻采样
+func scaleDown(dst *image.RGBA, src image.Image, srcBounds image.Rectangle) {
+	dstBounds := dst.Bounds()
+	srcWidth, srcHeight := srcBounds.Dx(), srcBounds.Dy()
+	dstWidth, dstHeight := dstBounds.Dx(), dstBounds.Dy()
+
+	for y := 0; y < dstHeight; y++ {
+		y0 := srcBounds.Min.Y + y*srcHeight/dstHeight
+		y1 := srcBounds.Min.Y + max((y+1)*srcHeight/dstHeight, y*srcHeight/dstHeight+1)
+		for x := 0; x < dstWidth; x++ {
+			x0 := srcBounds.Min.X + x*srcWidth/dstWidth
+			x1 := srcBounds.Min.X + max((x+1)*srcWidth/dstWidth, x*srcWidth/dstWidth+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					// RGBA返回预乘alpha的16位分量
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					b += uint64(pb)
+					a += uint64(pa)
+					n++
+				}
+			}
+			r, g, b, a = r/n, g/n, b/n, a/n
+			// 与白色背景混合：结果 = 前景(预乘) + 白色 * (1 - alpha)
+			background := 0xffff - a
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8((r + background) >> 8),
+				G: uint8((g + background) >> 8),
+				B: uint8((b + background) >> 8),
+				A: 0xff,
+			})
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package random
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// codeAlphabet 邀请码字符集，去掉了容易混淆的0、O、1、I、L
+const codeAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+/* GetRandomCode 使用加密安全的随机数生成指定长度的邀请码
+ * 参数:
+ *	length: 邀请码的长度
+ * 返回值:
+ *	string: 生成的邀请码，只包含大写字母和数字
+ *	error: 读取系统随机数失败时返回错误
+ * 示例:
+ *	GetRandomCode(8) // 返回类似 "K7MQ2XPA" 的字符串
+ */
+func GetRandomCode(length int) (string, error) {
+	code := make([]byte, length)
+	max := big.NewInt(int64(len(codeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = codeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}